@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/scraper/resilient"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+const (
+	runsDefaultLimit = 100
+	runsMaxLimit     = 1000
+)
+
+// manual scrapes hit all sources at once, so only a few are allowed
+const (
+	manualScrapeEvery = time.Minute
+	manualScrapeBurst = 3
+)
+
+type ScrapeRunJSON struct {
+	ID           int64     `json:"id"`
+	Scraper      string    `json:"scraper"`
+	Trigger      string    `json:"trigger"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	New          int32     `json:"new"`
+	Updated      int32     `json:"updated"`
+	Recent       int32     `json:"recent"`
+	ErrorCount   int32     `json:"error_count"`
+	Errors       []string  `json:"errors"`
+	DurationSecs float64   `json:"duration_seconds"`
+}
+
+func scrapeRunJSON(r model.ScrapeRun) ScrapeRunJSON {
+	errs := []string{}
+	if r.Errors != "" {
+		errs = strings.Split(r.Errors, "\n")
+	}
+	return ScrapeRunJSON{
+		ID:           r.ID,
+		Scraper:      r.Scraper,
+		Trigger:      r.Trigger,
+		StartedAt:    r.StartedAt,
+		FinishedAt:   r.FinishedAt,
+		New:          r.NewCount,
+		Updated:      r.UpdatedCount,
+		Recent:       r.RecentCount,
+		ErrorCount:   r.ErrorCount,
+		Errors:       errs,
+		DurationSecs: r.FinishedAt.Sub(r.StartedAt).Seconds(),
+	}
+}
+
+// listRuns returns the most recent scrape runs, optionally only of one
+// scraper or only failed ones.
+func listRuns(c echo.Context, db *model.DB) ([]model.ScrapeRun, model.ListScrapeRunsParams, error) {
+	limit, err := queryInt(c, "limit", runsDefaultLimit)
+	if err != nil {
+		return nil, model.ListScrapeRunsParams{}, err
+	}
+	params := model.ListScrapeRunsParams{
+		Scraper:    c.QueryParam("scraper"),
+		FailedOnly: c.QueryParam("failed") == "true",
+		MaxResults: int32(min(max(limit, 1), runsMaxLimit)),
+	}
+	runs, err := db.Queries().ListScrapeRuns(c.Request().Context(), params)
+	return runs, params, err
+}
+
+// secretEqual compares in constant time, hashing first so the length of the
+// secret does not leak either.
+func secretEqual(given, want string) bool {
+	g := sha256.Sum256([]byte(given))
+	w := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+}
+
+// bearerToken returns the token of a bearer Authorization header.
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get(echo.HeaderAuthorization), " ")
+	return token, ok && strings.EqualFold(scheme, "bearer")
+}
+
+func authorized(req *http.Request, conf config.Config) bool {
+	if token, ok := bearerToken(req); ok {
+		ok := false
+		for _, t := range conf.AdminTokens {
+			// no early return, every token is compared
+			if secretEqual(token, t) {
+				ok = true
+			}
+		}
+		return ok
+	}
+	if user, password, ok := req.BasicAuth(); ok {
+		want, found := conf.AdminUsers[user]
+		// compare anyway, so unknown users take as long as wrong passwords
+		return secretEqual(password, want) && found
+	}
+	return false
+}
+
+// adminAuth lets requests with a configured bearer token or basic auth
+// credentials through.
+func adminAuth(store *config.Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			conf := store.Get()
+			if !conf.AdminEnabled() {
+				return echo.NewHTTPError(http.StatusForbidden, "admin access is disabled, set -admin-tokens or -admin-users")
+			}
+			if !authorized(c.Request(), conf) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="serializer-go admin"`)
+				return echo.ErrUnauthorized
+			}
+			return next(c)
+		}
+	}
+}
+
+// bearerOnly rejects requests authorized with basic auth. Browsers send the
+// credentials along with cross-site requests, a bearer token has to be set
+// explicitly, so routes without a form don't need a CSRF token.
+func bearerOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := bearerToken(c.Request()); !ok {
+			return echo.NewHTTPError(http.StatusForbidden, "a bearer token from -admin-tokens is required")
+		}
+		return next(c)
+	}
+}
+
+// rateLimited rejects requests beyond the rate of limiter with 429.
+func rateLimited(limiter *rate.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := limiter.Reserve()
+			if d := r.Delay(); d > 0 {
+				r.Cancel()
+				c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(d.Seconds()))))
+				return echo.ErrTooManyRequests
+			}
+			return next(c)
+		}
+	}
+}
+
+// registerAdmin adds the pages to look after the app, all behind adminAuth.
+func registerAdmin(app *echo.Echo, db *model.DB, conf *config.Store, scheduler *job.Scheduler, transport *resilient.Transport) {
+	if c := conf.Get(); !c.AdminEnabled() {
+		slog.Warn("no admin credentials configured, /admin is disabled")
+	}
+	admin := app.Group("/admin", adminAuth(conf))
+
+	admin.GET("/runs", func(c echo.Context) error {
+		runs, params, err := listRuns(c, db)
+		if err != nil {
+			return err
+		}
+		return views.ScrapeRuns(runs, params.Scraper, params.FailedOnly).Render(c.Request().Context(), c.Response())
+	})
+
+	admin.GET("/api/runs", func(c echo.Context) error {
+		runs, _, err := listRuns(c, db)
+		if err != nil {
+			return err
+		}
+		resp := make([]ScrapeRunJSON, 0, len(runs))
+		for _, r := range runs {
+			resp = append(resp, scrapeRunJSON(r))
+		}
+		return c.JSON(http.StatusOK, map[string]any{"runs": resp})
+	})
+
+	// state of the circuit breakers per host, including the last upstream error
+	admin.GET("/status", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{"breakers": transport.Status()})
+	})
+
+	registerMutes(admin, db)
+
+	limiter := rate.NewLimiter(rate.Every(manualScrapeEvery), manualScrapeBurst)
+	admin.POST("/scrape", func(c echo.Context) error {
+		return scheduler.Trigger(func(r job.Result, err error) error {
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]any{
+					"result": r,
+					"errors": r.ErrorMessages(),
+				})
+			}
+			return c.JSON(http.StatusOK, r)
+		})
+	}, bearerOnly, rateLimited(limiter))
+}
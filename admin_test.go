@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/labstack/echo/v4"
+)
+
+func TestManualScrapeAuth(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	conf := config.Default()
+	conf.AdminTokens = []string{token}
+	conf.AdminUsers = map[string]string{"admin": "secret"}
+	// without scrapers a manual scrape returns right away
+	scheduler := job.Start(nil, config.Config{}, nil)
+	defer scheduler.Stop()
+
+	app := echo.New()
+	registerAdmin(app, nil, config.NewStore(conf), scheduler, nil)
+
+	tests := []struct {
+		name       string
+		auth       func(req *http.Request)
+		wantStatus int
+	}{
+		{
+			name:       "no credentials",
+			auth:       func(req *http.Request) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong token",
+			auth:       func(req *http.Request) { req.Header.Set(echo.HeaderAuthorization, "Bearer wrong") },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			// a cross-site form post from a page the admin visits looks
+			// just like this
+			name:       "basic auth",
+			auth:       func(req *http.Request) { req.SetBasicAuth("admin", "secret") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "bearer token",
+			auth:       func(req *http.Request) { req.Header.Set(echo.HeaderAuthorization, "Bearer "+token) },
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/scrape", nil)
+			tt.auth(req)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	// rejected requests don't use up the rate limit, one bearer request
+	// was let through above
+	for i := 1; i < manualScrapeBurst; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/admin/scrape", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/fs"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/floj/serializer-go/assets"
+	"github.com/floj/serializer-go/model"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	apiDefaultLimit = 30
+	apiMaxLimit     = 200
+)
+
+type StoriesResponse struct {
+	Stories []model.StoryJSON `json:"stories"`
+	// cursors for the next page, only set if there might be more stories
+	NextBefore *int64 `json:"next_before,omitempty"`
+	NextSince  *int64 `json:"next_since,omitempty"`
+}
+
+func queryInt(c echo.Context, name string, def int64) (int64, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid value for "+name)
+	}
+	return i, nil
+}
+
+func registerAPI(app *echo.Echo, db *model.DB) {
+	api := app.Group("/api/v1")
+
+	api.GET("/openapi.yaml", func(c echo.Context) error {
+		b, err := fs.ReadFile(assets.StaticAssets(), "openapi.yaml")
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "application/yaml", b)
+	})
+
+	// Without cursor the newest stories are returned. With since, stories
+	// after it are returned oldest first, so clients can poll for new ones.
+	// With before, stories before it are returned newest first to page back.
+	api.GET("/stories", func(c echo.Context) error {
+		since, err := queryInt(c, "since", 0)
+		if err != nil {
+			return err
+		}
+		before, err := queryInt(c, "before", math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		limit, err := queryInt(c, "limit", apiDefaultLimit)
+		if err != nil {
+			return err
+		}
+		limit = min(max(limit, 1), apiMaxLimit)
+		filter := filterFromQuery(c)
+
+		ctx := c.Request().Context()
+		queries := db.Queries()
+		ascending := c.QueryParam("since") != "" && c.QueryParam("before") == ""
+
+		stories, err := queries.ListStories(ctx, model.ListStoriesParams{
+			Since:      since,
+			Before:     before,
+			Scrapers:   strings.Join(filter.Scrapers, ","),
+			Types:      strings.Join(filter.Types, ","),
+			Ascending:  ascending,
+			MaxResults: int32(limit),
+		})
+		if err != nil {
+			return err
+		}
+
+		muter, err := model.LoadMuter(ctx, queries)
+		if err != nil {
+			return err
+		}
+
+		// muted stories are left out, the cursors still point past them
+		resp := StoriesResponse{Stories: make([]model.StoryJSON, 0, len(stories))}
+		for _, s := range stories {
+			if muter.Muted(&s) {
+				continue
+			}
+			resp.Stories = append(resp.Stories, s.JSON())
+		}
+		if len(stories) > 0 {
+			last := stories[len(stories)-1].ID
+			if ascending {
+				// polling continues from the newest story, even if the page is not full
+				resp.NextSince = &last
+			} else if int64(len(stories)) == limit {
+				resp.NextBefore = &last
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
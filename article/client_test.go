@@ -0,0 +1,125 @@
+package article
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:4700:4700::1111]:443", true},
+		{"[::ffff:93.184.215.14]:443", true},
+		// loopback
+		{"127.0.0.1:80", false},
+		{"127.1.2.3:80", false},
+		{"[::1]:80", false},
+		// RFC 1918 and unique local
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"172.31.255.255:80", false},
+		{"192.168.1.1:80", false},
+		{"[fd00::1]:80", false},
+		// link-local, including the cloud metadata service
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		// IPv4-mapped IPv6
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:10.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		// NAT64 of a private address
+		{"[64:ff9b::a00:1]:80", false},
+		// other special purpose ranges
+		{"0.0.0.0:80", false},
+		{"100.64.0.1:80", false},
+		{"198.18.0.1:80", false},
+		{"224.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+		{"[::]:80", false},
+	}
+	for _, tt := range tests {
+		err := publicOnly("tcp", tt.address, nil)
+		if tt.public && err != nil {
+			t.Errorf("%s: got error %v, want it allowed", tt.address, err)
+		}
+		if !tt.public && !errors.Is(err, errNonPublicAddress) {
+			t.Errorf("%s: got error %v, want it refused", tt.address, err)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	// the server listens on 127.0.0.1, so is refused before any request,
+	// also when the url names it differently
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := NewClient().Get(u)
+		if !errors.Is(err, errNonPublicAddress) || !isPermanent(err) {
+			t.Errorf("%s: got error %v, want a permanent non-public address error", u, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("server got %d requests, want none", requests)
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	tests := []struct {
+		location string
+		wantErr  string
+	}{
+		{"/other", ""},
+		{"file:///etc/passwd", `unsupported scheme "file"`},
+		{"ftp://example.com/article", `unsupported scheme "ftp"`},
+		{"gopher://example.com/", `unsupported scheme "gopher"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					http.Redirect(w, r, tt.location, http.StatusFound)
+				}
+			}))
+			defer srv.Close()
+
+			// a plain transport, the redirect check is what is tested here
+			httpc := &http.Client{CheckRedirect: checkRedirect}
+			resp, err := httpc.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !isPermanent(err) {
+				t.Errorf("got error %v, want a permanent %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRedirectLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := (&http.Client{CheckRedirect: checkRedirect}).Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "stopped after 5 redirects") || !isPermanent(err) {
+		t.Errorf("got error %v, want a permanent redirect limit error", err)
+	}
+}
@@ -0,0 +1,266 @@
+package article
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Extracted is the main text of a page.
+type Extracted struct {
+	Title      string
+	Paragraphs []string
+}
+
+// Words returns the number of words of all paragraphs.
+func (e *Extracted) Words() int {
+	n := 0
+	for _, p := range e.Paragraphs {
+		n += len(strings.Fields(p))
+	}
+	return n
+}
+
+// elements that never hold article text
+var skipElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Svg: true, atom.Form: true, atom.Nav: true, atom.Header: true,
+	atom.Footer: true, atom.Aside: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Template: true, atom.Dialog: true,
+}
+
+// elements whose text becomes a paragraph of the article
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Blockquote: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+var (
+	positiveClass = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text|blog`)
+	negativeClass = regexp.MustCompile(`(?i)comment|sidebar|footer|footnote|nav|menu|share|social|related|promo|sponsor|advert|banner|cookie|newsletter|subscribe|popup|modal|meta|byline|author|tags|breadcrumb|masthead|widget`)
+)
+
+// minimum length of the text of a paragraph to count for its container
+const minParagraphLen = 25
+
+// Extract returns the main text of the HTML page in r. Like readability, it
+// scores the containers of the paragraphs by their amount of text and commas
+// and by hints in their class and id, and keeps the best one together with
+// siblings that score almost as well.
+func Extract(r io.Reader) (Extracted, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Extracted{}, err
+	}
+	e := Extracted{Title: pageTitle(doc)}
+
+	scores := map[*html.Node]float64{}
+	addScore := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = classWeight(n) + tagWeight(n)
+		}
+		scores[n] += s
+	}
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre {
+			return true
+		}
+		text := nodeText(n)
+		if len(text) < minParagraphLen {
+			return false
+		}
+		s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		addScore(n.Parent, s)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, s/2)
+		}
+		return false
+	})
+
+	var top *html.Node
+	topScore := 0.0
+	for n, s := range scores {
+		s *= 1 - linkDensity(n)
+		scores[n] = s
+		if top == nil || s > topScore {
+			top, topScore = n, s
+		}
+	}
+	if top == nil {
+		return e, nil
+	}
+
+	// siblings of the best container often hold the rest of the article,
+	// e.g. when every section has its own div
+	containers := []*html.Node{top}
+	if top.Parent != nil {
+		containers = containers[:0]
+		threshold := max(10, topScore*0.2)
+		for c := top.Parent.FirstChild; c != nil; c = c.NextSibling {
+			if c == top || scores[c] >= threshold {
+				containers = append(containers, c)
+			}
+		}
+	}
+	for _, c := range containers {
+		e.Paragraphs = append(e.Paragraphs, paragraphs(c)...)
+	}
+	return e, nil
+}
+
+// walk calls fn for n and its descendants, skipping the elements that never
+// hold article text. Returning false skips the children of the node.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if n.Type == html.ElementNode && (skipElements[n.DataAtom] || unlikely(n)) {
+		return
+	}
+	if n.Type == html.ElementNode && !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+// paragraphs returns the text of the blocks in n, in document order.
+func paragraphs(n *html.Node) []string {
+	l := []string{}
+	walk(n, func(b *html.Node) bool {
+		if !blockElements[b.DataAtom] {
+			return true
+		}
+		// lists of links are navigation more often than not
+		if text := nodeText(b); text != "" && (len(text) >= minParagraphLen || linkDensity(b) < 0.5) {
+			l = append(l, text)
+		}
+		return false
+	})
+	return l
+}
+
+func pageTitle(doc *html.Node) string {
+	title := ""
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Meta:
+				if attr(n, "property") == "og:title" && attr(n, "content") != "" {
+					title = attr(n, "content")
+					return
+				}
+			case atom.Title:
+				if title == "" {
+					title = nodeText(n)
+				}
+			case atom.Body:
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	return strings.TrimSpace(title)
+}
+
+// nodeText returns the text of n and its descendants with whitespace
+// collapsed.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+			return
+		}
+		if n.Type == html.ElementNode && skipElements[n.DataAtom] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// linkDensity is the share of the text of n that is part of a link.
+func linkDensity(n *html.Node) float64 {
+	total := len(nodeText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			linked += len(nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(n)
+	return float64(linked) / float64(total)
+}
+
+func classWeight(n *html.Node) float64 {
+	w := 0.0
+	for _, v := range []string{attr(n, "class"), attr(n, "id")} {
+		if v == "" {
+			continue
+		}
+		if negativeClass.MatchString(v) {
+			w -= 25
+		}
+		if positiveClass.MatchString(v) {
+			w += 25
+		}
+	}
+	return w
+}
+
+func tagWeight(n *html.Node) float64 {
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		return 10
+	case atom.Div:
+		return 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		return 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li:
+		return -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		return -5
+	}
+	return 0
+}
+
+// unlikely reports whether the class or id of n marks it as something else
+// than the article, like comments or a sidebar.
+func unlikely(n *html.Node) bool {
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	return negativeClass.MatchString(hints) && !positiveClass.MatchString(hints)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
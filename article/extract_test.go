@@ -0,0 +1,75 @@
+package article
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/floj/serializer-go/model"
+)
+
+func TestExtract(t *testing.T) {
+	page, err := os.ReadFile("testdata/article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		page         string
+		wantTitle    string
+		wantWords    int
+		wantMinutes  int32
+		wantFirst    string
+		wantExcluded []string
+	}{
+		{
+			name:        "blog post",
+			page:        string(page),
+			wantTitle:   "Why the scheduler stalled",
+			wantWords:   224,
+			wantMinutes: 1,
+			wantFirst:   "Last week our background scheduler stopped picking up new work",
+			// navigation, sidebar, comments and footer
+			wantExcluded: []string{"Archive", "caching layer", "Great write-up", "Copyright"},
+		},
+		{
+			name:        "long text",
+			page:        "<html><head><title>Long</title></head><body><div><p>" + strings.Repeat("word, ", 400) + "</p></div></body></html>",
+			wantTitle:   "Long",
+			wantWords:   400,
+			wantMinutes: 2,
+			wantFirst:   "word, word,",
+		},
+		{
+			name:      "no article",
+			page:      "<html><head><title>Login</title></head><body><form><p>Please log in to continue reading this page.</p></form></body></html>",
+			wantTitle: "Login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Extract(strings.NewReader(tt.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Title != tt.wantTitle {
+				t.Errorf("got title %q, want %q", e.Title, tt.wantTitle)
+			}
+			if got := e.Words(); got != tt.wantWords {
+				t.Errorf("got %d words, want %d", got, tt.wantWords)
+			}
+			if got := model.ReadingMinutes(e.Words()); got != tt.wantMinutes {
+				t.Errorf("got a reading time of %d minutes, want %d", got, tt.wantMinutes)
+			}
+			if tt.wantFirst != "" && (len(e.Paragraphs) == 0 || !strings.HasPrefix(e.Paragraphs[0], tt.wantFirst)) {
+				t.Errorf("got paragraphs %q, want the first to start with %q", e.Paragraphs, tt.wantFirst)
+			}
+			text := strings.Join(e.Paragraphs, "\n\n")
+			for _, s := range tt.wantExcluded {
+				if strings.Contains(text, s) {
+					t.Errorf("got %q in the article text", s)
+				}
+			}
+		})
+	}
+}
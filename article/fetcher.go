@@ -0,0 +1,165 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/model"
+	"golang.org/x/net/html/charset"
+)
+
+// pages are cut off after this many bytes
+const maxPageSize = 5 << 20
+
+// pages with fewer words are not articles, e.g. videos or landing pages
+const minArticleWords = 100
+
+// some sites reject requests with the default user agent of the http client
+const userAgent = "serializer-go/1.0 (+https://github.com/floj/serializer-go)"
+
+// articles that failed for a reason that may go away are fetched again after
+// retryDelay, up to maxAttempts times in total
+const (
+	retryDelay  = time.Hour
+	maxAttempts = 3
+)
+
+// permanentError marks failures that won't go away by fetching again, like a
+// missing page or a video.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p) || errors.Is(err, errNonPublicAddress)
+}
+
+// Fetcher downloads the articles of link stories in the background and stores
+// their text. It follows the articles config, so it can be turned on and off
+// by reloading the config. httpc should come from NewClient.
+type Fetcher struct {
+	db    *model.DB
+	httpc *http.Client
+	conf  *config.Store
+}
+
+func NewFetcher(db *model.DB, httpc *http.Client, conf *config.Store) *Fetcher {
+	return &Fetcher{db: db, httpc: httpc, conf: conf}
+}
+
+// Run fetches batches of articles until ctx is done.
+func (f *Fetcher) Run(ctx context.Context) {
+	for {
+		conf := f.conf.Get().Articles
+		if conf.Enabled {
+			if err := f.fetchBatch(ctx, conf); err != nil && ctx.Err() == nil {
+				slog.Error("could not fetch articles", "err", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(conf.Interval):
+		}
+	}
+}
+
+// fetchBatch fetches the articles of the newest stories that don't have one
+// yet. Failures are stored as well, so broken links are not fetched again,
+// except for transient ones, which are retried after retryDelay.
+func (f *Fetcher) fetchBatch(ctx context.Context, conf config.ArticlesConfig) error {
+	queries := f.db.Queries()
+	stories, err := queries.ListStoriesWithoutArticle(ctx, model.ListStoriesWithoutArticleParams{
+		Since:       time.Now().Add(-conf.MaxAge),
+		MaxAttempts: maxAttempts,
+		RetryBefore: time.Now().Add(-retryDelay),
+		MaxResults:  int32(conf.BatchSize),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list stories without article: %w", err)
+	}
+	for _, s := range stories {
+		params := model.SaveArticleParams{StoryID: s.ID, Status: model.ArticleOK, FetchedAt: time.Now()}
+		e, err := f.fetch(ctx, s.Url, conf.Timeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil && e.Words() < minArticleWords {
+			err = permanent(fmt.Errorf("no article text found"))
+		}
+		if err != nil {
+			slog.Debug("could not extract article", "story", s.ID, "url", s.Url, "err", err)
+			params.Status = model.ArticleRetry
+			if isPermanent(err) {
+				params.Status = model.ArticleFailed
+			}
+			params.Error = err.Error()
+		} else {
+			params.Title = e.Title
+			params.Content = strings.Join(e.Paragraphs, "\n\n")
+			params.WordCount = int32(e.Words())
+			params.ReadingMinutes = model.ReadingMinutes(e.Words())
+		}
+		if _, err := queries.SaveArticle(ctx, params); err != nil {
+			return fmt.Errorf("could not save article of story %d: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, url string, timeout time.Duration) (Extracted, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Extracted{}, permanent(err)
+	}
+	if err := checkScheme(req.URL.Scheme); err != nil {
+		return Extracted{}, permanent(err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.httpc.Do(req)
+	if err != nil {
+		return Extracted{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests {
+			return Extracted{}, err
+		}
+		return Extracted{}, permanent(err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Extracted{}, permanent(fmt.Errorf("not an html page but %q", mediaType))
+	}
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), contentType)
+	if err != nil {
+		return Extracted{}, fmt.Errorf("could not decode page: %w", err)
+	}
+	e, err := Extract(body)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return e, fmt.Errorf("timeout after %s", timeout)
+	}
+	return e, err
+}
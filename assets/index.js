@@ -39,3 +39,55 @@
   observer.observe(topForm);
   observer.observe(lastRead);
 })();
+
+(() => {
+  const toggle = document.querySelector("#settings-toggle a");
+  const panel = document.querySelector("#settings-panel");
+
+  if (!toggle || !panel) {
+    return;
+  }
+
+  toggle.onclick = (evt) => {
+    evt.preventDefault();
+    panel.classList.toggle("hidden");
+  };
+
+  for (const input of panel.querySelectorAll(".source-toggle input")) {
+    input.onchange = () => {
+      input.parentElement.classList.toggle("enabled", input.checked);
+    };
+  }
+})();
+
+(() => {
+  const topForm = document.querySelector("form.log-button");
+  const tbody = document.querySelector("#item-table tbody");
+
+  if (!topForm || !tbody || !window.EventSource) {
+    return;
+  }
+
+  const latest = topForm.querySelector("input[name=last]");
+  const message = topForm.querySelector(".message");
+
+  const params = new URLSearchParams({ since: latest.value });
+  if (topForm.dataset.session) {
+    params.set("session", topForm.dataset.session);
+  }
+
+  const events = new EventSource("/events?" + params);
+  events.addEventListener("stories", (evt) => {
+    const data = JSON.parse(evt.data);
+    tbody.insertAdjacentHTML("afterbegin", data.html);
+    latest.value = data.latest;
+
+    const unread = Number(topForm.dataset.unread) + data.unread;
+    topForm.dataset.unread = unread;
+    if (unread > 0) {
+      topForm.classList.remove("catched-up");
+      message.textContent = `Mark all ${unread} as read`;
+      document.title = `${unread} - serializer.go`;
+    }
+  });
+})();
@@ -2,14 +2,149 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
+	Listen         string
 	DBURI          string
 	ScrapeInterval time.Duration
 	ScrapeTimeout  time.Duration
 	CookieSecure   bool
+	Scrapers       []string
+	HNBackend      string
+	// HNPageSize is the number of stories fetched from the HN front page
+	HNPageSize int
+	Feeds      []FeedConfig
+	Subreddits []SubredditConfig
+	Schedules  map[string]ScheduleConfig
+	// StaleAfter is how long a scraper may go without a successful run before
+	// the health check fails, zero derives it from the schedule
+	StaleAfter        time.Duration
+	StaleAfterScraper map[string]time.Duration
+	// AdminTokens and AdminUsers grant access to /admin as bearer token or
+	// basic auth user and password, without any of them /admin is disabled
+	AdminTokens []string
+	AdminUsers  map[string]string
+	// stories that left the front page are updated if they were not updated
+	// for RecentUpdateAfter and are younger than RecentMaxAge
+	RecentUpdateAfter time.Duration
+	RecentMaxAge      time.Duration
+	URLTransformers   []URLTransformerConfig
+	UI                UIConfig
+	Articles          ArticlesConfig
+}
+
+// ArticlesConfig controls the background fetcher that extracts the article
+// text of link stories.
+type ArticlesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the pause between two batches of BatchSize articles
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batch_size"`
+	// Timeout limits fetching a single article
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAge skips stories collected longer ago
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+type FeedConfig struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	Icon string `yaml:"icon"`
+}
+
+type SubredditConfig struct {
+	Name     string `yaml:"name"`
+	MinScore int    `yaml:"min_score"`
+}
+
+// ScheduleConfig overrides the schedule of a scraper, zero values keep the
+// schedule the scraper declares or the default.
+type ScheduleConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Jitter   time.Duration `yaml:"jitter"`
+}
+
+const (
+	URLTransformerFarside  = "farside"
+	URLTransformerFrontend = "frontend"
+	URLTransformerRegex    = "regex"
+	URLTransformerArchive  = "archive"
+)
+
+// URLTransformerConfig rewrites the links of stories, the first matching
+// transformer wins.
+type URLTransformerConfig struct {
+	Type string `yaml:"type"`
+	// Host and Hosts are matched including subdomains
+	Host  string   `yaml:"host"`
+	Hosts []string `yaml:"hosts"`
+	// Service is the farside service to redirect to, e.g. nitter, or the
+	// archive, archive.org or archive.today
+	Service string `yaml:"service"`
+	// Frontend is the base url links are moved to, e.g. https://nitter.net
+	Frontend string `yaml:"frontend"`
+	// Pattern is matched against the whole link, which is replaced by Replace
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+}
+
+// AllHosts returns Host and Hosts.
+func (t *URLTransformerConfig) AllHosts() []string {
+	if t.Host == "" {
+		return t.Hosts
+	}
+	return append([]string{t.Host}, t.Hosts...)
+}
+
+func (t *URLTransformerConfig) Validate() error {
+	switch t.Type {
+	case URLTransformerFarside:
+		if t.Host == "" || t.Service == "" {
+			return fmt.Errorf("%s transformer requires host and service", t.Type)
+		}
+	case URLTransformerFrontend:
+		if len(t.AllHosts()) == 0 || t.Frontend == "" {
+			return fmt.Errorf("%s transformer requires hosts and frontend", t.Type)
+		}
+		u, err := url.Parse(t.Frontend)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid frontend %q, expected an http or https url", t.Frontend)
+		}
+	case URLTransformerRegex:
+		if t.Pattern == "" {
+			return fmt.Errorf("%s transformer requires pattern", t.Type)
+		}
+		if _, err := regexp.Compile(t.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	case URLTransformerArchive:
+		if len(t.AllHosts()) == 0 {
+			return fmt.Errorf("%s transformer requires hosts", t.Type)
+		}
+		if t.Service != "archive.org" && t.Service != "archive.today" {
+			return fmt.Errorf("invalid archive %q, expected archive.org or archive.today", t.Service)
+		}
+	default:
+		return fmt.Errorf("unknown transformer type %q, expected one of %s, %s, %s, %s", t.Type, URLTransformerFarside, URLTransformerFrontend, URLTransformerRegex, URLTransformerArchive)
+	}
+	return nil
+}
+
+// UIConfig holds the defaults of new sessions.
+type UIConfig struct {
+	HiddenScrapers []string `yaml:"hidden_scrapers"`
+	// HiddenTypes are given as scraper:type
+	HiddenTypes []string `yaml:"hidden_types"`
+	ShowMuted   bool     `yaml:"show_muted"`
+	// RewriteLinks applies the URL transformers
+	RewriteLinks bool `yaml:"rewrite_links"`
 }
 
 func (c *Config) ScrapeEnabled() bool {
@@ -20,24 +155,177 @@ func (c *Config) HasScrapeTimeout() bool {
 	return c.ScrapeTimeout > time.Duration(0)
 }
 
-func Create(dbURI, scrapeInterval, scrapeTimeout string, cookieSecure bool) (Config, error) {
-	c := Config{
-		DBURI:        dbURI,
-		CookieSecure: cookieSecure,
+func (c *Config) AdminEnabled() bool {
+	return len(c.AdminTokens) > 0 || len(c.AdminUsers) > 0
+}
+
+func splitList(s string) []string {
+	l := []string{}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			l = append(l, e)
+		}
 	}
-	{
-		d, err := time.ParseDuration(scrapeInterval)
-		if err != nil {
-			return c, fmt.Errorf("failed to parse scrape interval: %w", err)
+	return l
+}
+
+var feedNameRegexp = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ParseFeeds parses a comma separated list of feeds in the form
+// name=url or name=url|icon.
+func ParseFeeds(s string) ([]FeedConfig, error) {
+	feeds := []FeedConfig{}
+	seen := map[string]bool{}
+	for _, e := range splitList(s) {
+		name, rest, ok := strings.Cut(e, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid feed %q, expected name=url", e)
+		}
+		f := FeedConfig{Name: strings.TrimSpace(name)}
+		f.URL, f.Icon, _ = strings.Cut(rest, "|")
+		if err := f.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("feed %q is defined more than once", f.Name)
 		}
-		c.ScrapeInterval = d.Abs()
+		seen[f.Name] = true
+		feeds = append(feeds, f)
 	}
-	{
-		d, err := time.ParseDuration(scrapeTimeout)
+	return feeds, nil
+}
+
+func (f *FeedConfig) Validate() error {
+	if !feedNameRegexp.MatchString(f.Name) {
+		return fmt.Errorf("invalid feed name %q, only lower case letters, digits, '-' and '_' are allowed", f.Name)
+	}
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url for feed %q: %w", f.Name, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url for feed %q, expected http or https, got %q", f.Name, f.URL)
+	}
+	return nil
+}
+
+var subredditRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ParseSubreddits parses a comma separated list of subreddits in the form
+// name or name:minscore.
+func ParseSubreddits(s string) ([]SubredditConfig, error) {
+	subs := []SubredditConfig{}
+	for _, e := range splitList(s) {
+		name, minScore, hasScore := strings.Cut(e, ":")
+		sub := SubredditConfig{Name: strings.TrimPrefix(strings.TrimSpace(name), "r/")}
+		if err := sub.Validate(); err != nil {
+			return nil, err
+		}
+		if hasScore {
+			v, err := strconv.Atoi(strings.TrimSpace(minScore))
+			if err != nil {
+				return nil, fmt.Errorf("invalid min score for subreddit %q: %w", sub.Name, err)
+			}
+			sub.MinScore = v
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (s *SubredditConfig) Validate() error {
+	if !subredditRegexp.MatchString(s.Name) {
+		return fmt.Errorf("invalid subreddit name %q", s.Name)
+	}
+	return nil
+}
+
+// ParseSchedules parses a comma separated list of scraper schedules in the form
+// name=interval[:timeout[:jitter]], empty values keep the default.
+func ParseSchedules(s string) (map[string]ScheduleConfig, error) {
+	schedules := map[string]ScheduleConfig{}
+	for _, e := range splitList(s) {
+		name, rest, ok := strings.Cut(e, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid schedule %q, expected name=interval[:timeout[:jitter]]", e)
+		}
+		if _, ok := schedules[name]; ok {
+			return nil, fmt.Errorf("schedule for %q is defined more than once", name)
+		}
+		parts := strings.Split(rest, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid schedule %q, expected name=interval[:timeout[:jitter]]", e)
+		}
+		durations := [3]time.Duration{}
+		for i, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			d, err := time.ParseDuration(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schedule for %q: %w", name, err)
+			}
+			durations[i] = d.Abs()
+		}
+		schedules[name] = ScheduleConfig{Interval: durations[0], Timeout: durations[1], Jitter: durations[2]}
+	}
+	return schedules, nil
+}
+
+// ParseStaleAfter parses a comma separated list of durations. An entry without
+// name sets the default, name=duration the one of a single scraper.
+func ParseStaleAfter(s string) (time.Duration, map[string]time.Duration, error) {
+	def := time.Duration(0)
+	perScraper := map[string]time.Duration{}
+	for _, e := range splitList(s) {
+		name, v, hasName := strings.Cut(e, "=")
+		if !hasName {
+			v = name
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
 		if err != nil {
-			return c, fmt.Errorf("failed to parse scrape timeout: %w", err)
+			return 0, nil, fmt.Errorf("invalid duration in %q: %w", e, err)
+		}
+		if !hasName {
+			def = d.Abs()
+			continue
+		}
+		perScraper[strings.TrimSpace(name)] = d.Abs()
+	}
+	return def, perScraper, nil
+}
+
+// tokens are meant to be generated, anything shorter is likely a placeholder
+const minAdminTokenLen = 16
+
+// ParseAdminTokens parses a comma separated list of bearer tokens.
+func ParseAdminTokens(s string) ([]string, error) {
+	tokens := splitList(s)
+	for i, t := range tokens {
+		if len(t) < minAdminTokenLen {
+			return nil, fmt.Errorf("admin token %d is too short, expected at least %d characters", i+1, minAdminTokenLen)
+		}
+	}
+	return tokens, nil
+}
+
+// ParseAdminUsers parses a comma separated list of basic auth credentials in
+// the form user:password.
+func ParseAdminUsers(s string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, e := range splitList(s) {
+		user, password, ok := strings.Cut(e, ":")
+		user = strings.TrimSpace(user)
+		if !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("invalid admin user %q, expected user:password", user)
+		}
+		if _, ok := users[user]; ok {
+			return nil, fmt.Errorf("admin user %q is defined more than once", user)
 		}
-		c.ScrapeTimeout = d.Abs()
+		users[user] = password
 	}
-	return c, nil
+	return users, nil
 }
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the layout of the YAML config file. Unset values keep the defaults.
+type File struct {
+	Listen         string         `yaml:"listen"`
+	DBURI          string         `yaml:"db_uri"`
+	ScrapeInterval *time.Duration `yaml:"scrape_interval"`
+	ScrapeTimeout  *time.Duration `yaml:"scrape_timeout"`
+	CookieSecure   *bool          `yaml:"cookie_secure"`
+	Scrapers       []string       `yaml:"scrapers"`
+	HN             struct {
+		Backend  string `yaml:"backend"`
+		PageSize int    `yaml:"page_size"`
+	} `yaml:"hn"`
+	Feeds      []FeedConfig      `yaml:"feeds"`
+	Subreddits []SubredditConfig `yaml:"subreddits"`
+	Schedules  map[string]struct {
+		ScheduleConfig `yaml:",inline"`
+		StaleAfter     time.Duration `yaml:"stale_after"`
+	} `yaml:"schedules"`
+	StaleAfter time.Duration `yaml:"stale_after"`
+	Recent     struct {
+		UpdateAfter time.Duration `yaml:"update_after"`
+		MaxAge      time.Duration `yaml:"max_age"`
+	} `yaml:"recent"`
+	URLTransformers []URLTransformerConfig `yaml:"url_transformers"`
+	UI              UIConfig               `yaml:"ui"`
+	Articles        ArticlesConfig         `yaml:"articles"`
+	Admin           struct {
+		Tokens []string          `yaml:"tokens"`
+		Users  map[string]string `yaml:"users"`
+	} `yaml:"admin"`
+}
+
+// Default returns the config used without config file, flags and env vars.
+func Default() Config {
+	return Config{
+		Listen:            ":3000",
+		ScrapeInterval:    time.Minute,
+		ScrapeTimeout:     time.Minute,
+		CookieSecure:      true,
+		Scrapers:          []string{"hn"}, // new sources are opt-in, see README
+		HNBackend:         "algolia",
+		HNPageSize:        30,
+		Feeds:             []FeedConfig{},
+		Subreddits:        []SubredditConfig{},
+		Schedules:         map[string]ScheduleConfig{},
+		StaleAfterScraper: map[string]time.Duration{},
+		RecentUpdateAfter: 15 * time.Minute,
+		RecentMaxAge:      24 * time.Hour,
+		AdminUsers:        map[string]string{},
+		UI:                UIConfig{RewriteLinks: true},
+		Articles: ArticlesConfig{
+			Interval:  time.Minute,
+			BatchSize: 10,
+			Timeout:   30 * time.Second,
+			MaxAge:    24 * time.Hour,
+		},
+	}
+}
+
+// Load reads the config file at path on top of the defaults, an empty path
+// skips the file. overrides are the flags and env vars that were set, keyed by
+// flag name, they take precedence over the file.
+func Load(path string, overrides map[string]string) (Config, error) {
+	c := Default()
+	if path != "" {
+		r, err := os.Open(path)
+		if err != nil {
+			return c, fmt.Errorf("could not read config file: %w", err)
+		}
+		defer r.Close()
+		f, err := decodeFile(r, c)
+		if err != nil {
+			return c, fmt.Errorf("could not parse config file %s: %w", path, err)
+		}
+		if err := f.apply(&c); err != nil {
+			return c, fmt.Errorf("invalid config file %s:\n%w", path, err)
+		}
+	}
+	if err := applyOverrides(&c, overrides); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// decodeFile reads the file on top of the defaults in c, so values without a
+// natural zero value can be left out.
+func decodeFile(r io.Reader, c Config) (File, error) {
+	f := File{UI: c.UI, Articles: c.Articles}
+	dec := yaml.NewDecoder(r)
+	// typos would otherwise silently keep the default
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return f, err
+	}
+	return f, nil
+}
+
+// apply copies the values set in the file to c. All invalid values are
+// reported at once, each with its path in the file.
+func (f *File) apply(c *Config) error {
+	errs := []error{}
+	invalid := func(path string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+	notNegative := func(path string, d time.Duration) {
+		if d < 0 {
+			invalid(path, fmt.Errorf("must not be negative, got %s", d))
+		}
+	}
+
+	if f.Listen != "" {
+		c.Listen = f.Listen
+	}
+	if f.DBURI != "" {
+		c.DBURI = f.DBURI
+	}
+	if f.ScrapeInterval != nil {
+		notNegative("scrape_interval", *f.ScrapeInterval)
+		c.ScrapeInterval = *f.ScrapeInterval
+	}
+	if f.ScrapeTimeout != nil {
+		notNegative("scrape_timeout", *f.ScrapeTimeout)
+		c.ScrapeTimeout = *f.ScrapeTimeout
+	}
+	if f.CookieSecure != nil {
+		c.CookieSecure = *f.CookieSecure
+	}
+	if f.Scrapers != nil {
+		c.Scrapers = f.Scrapers
+	}
+	if f.HN.Backend != "" {
+		c.HNBackend = f.HN.Backend
+	}
+	if f.HN.PageSize < 0 {
+		invalid("hn.page_size", fmt.Errorf("must not be negative, got %d", f.HN.PageSize))
+	} else if f.HN.PageSize > 0 {
+		c.HNPageSize = f.HN.PageSize
+	}
+
+	seen := map[string]bool{}
+	for i, feed := range f.Feeds {
+		if err := feed.Validate(); err != nil {
+			invalid(fmt.Sprintf("feeds[%d]", i), err)
+		} else if seen[feed.Name] {
+			invalid(fmt.Sprintf("feeds[%d]", i), fmt.Errorf("feed %q is defined more than once", feed.Name))
+		}
+		seen[feed.Name] = true
+	}
+	if f.Feeds != nil {
+		c.Feeds = f.Feeds
+	}
+	for i, sub := range f.Subreddits {
+		sub.Name = strings.TrimPrefix(sub.Name, "r/")
+		if err := sub.Validate(); err != nil {
+			invalid(fmt.Sprintf("subreddits[%d]", i), err)
+		}
+		f.Subreddits[i] = sub
+	}
+	if f.Subreddits != nil {
+		c.Subreddits = f.Subreddits
+	}
+
+	// sorted, so the errors come in a stable order
+	names := make([]string, 0, len(f.Schedules))
+	for name := range f.Schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := f.Schedules[name]
+		path := "schedules." + name
+		notNegative(path+".interval", s.Interval)
+		notNegative(path+".timeout", s.Timeout)
+		notNegative(path+".jitter", s.Jitter)
+		notNegative(path+".stale_after", s.StaleAfter)
+		c.Schedules[name] = s.ScheduleConfig
+		if s.StaleAfter > 0 {
+			c.StaleAfterScraper[name] = s.StaleAfter
+		}
+	}
+	notNegative("stale_after", f.StaleAfter)
+	c.StaleAfter = f.StaleAfter
+
+	notNegative("recent.update_after", f.Recent.UpdateAfter)
+	notNegative("recent.max_age", f.Recent.MaxAge)
+	if f.Recent.UpdateAfter > 0 {
+		c.RecentUpdateAfter = f.Recent.UpdateAfter
+	}
+	if f.Recent.MaxAge > 0 {
+		c.RecentMaxAge = f.Recent.MaxAge
+	}
+
+	for i, t := range f.URLTransformers {
+		if err := t.Validate(); err != nil {
+			invalid(fmt.Sprintf("url_transformers[%d]", i), err)
+		}
+	}
+	c.URLTransformers = f.URLTransformers
+
+	for i, t := range f.UI.HiddenTypes {
+		if _, _, ok := strings.Cut(t, ":"); !ok {
+			invalid(fmt.Sprintf("ui.hidden_types[%d]", i), fmt.Errorf("expected scraper:type, got %q", t))
+		}
+	}
+	c.UI = f.UI
+
+	positive := func(path string, d time.Duration) {
+		if d <= 0 {
+			invalid(path, fmt.Errorf("must be positive, got %s", d))
+		}
+	}
+	positive("articles.interval", f.Articles.Interval)
+	positive("articles.timeout", f.Articles.Timeout)
+	positive("articles.max_age", f.Articles.MaxAge)
+	if f.Articles.BatchSize <= 0 {
+		invalid("articles.batch_size", fmt.Errorf("must be positive, got %d", f.Articles.BatchSize))
+	}
+	c.Articles = f.Articles
+
+	for i, t := range f.Admin.Tokens {
+		if len(t) < minAdminTokenLen {
+			invalid(fmt.Sprintf("admin.tokens[%d]", i), fmt.Errorf("too short, expected at least %d characters", minAdminTokenLen))
+		}
+	}
+	c.AdminTokens = f.Admin.Tokens
+	for user, password := range f.Admin.Users {
+		if user == "" || password == "" {
+			invalid("admin.users", fmt.Errorf("user and password must not be empty"))
+		}
+	}
+	if f.Admin.Users != nil {
+		c.AdminUsers = f.Admin.Users
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyOverrides parses the flags and env vars in overrides into c, they
+// replace the values from the file.
+func applyOverrides(c *Config, overrides map[string]string) error {
+	// sorted, so the first error is stable
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := overrides[name]
+		var err error
+		switch name {
+		case "listen":
+			c.Listen = v
+		case "db-uri":
+			c.DBURI = v
+		case "scrape-interval":
+			c.ScrapeInterval, err = parseDuration(v)
+		case "scrape-timeout":
+			c.ScrapeTimeout, err = parseDuration(v)
+		case "cookie-insecure":
+			var insecure bool
+			insecure, err = strconv.ParseBool(v)
+			c.CookieSecure = !insecure
+		case "scrapers":
+			c.Scrapers = splitList(v)
+		case "hn-backend":
+			c.HNBackend = v
+		case "feeds":
+			c.Feeds, err = ParseFeeds(v)
+		case "subreddits":
+			c.Subreddits, err = ParseSubreddits(v)
+		case "schedules":
+			c.Schedules, err = ParseSchedules(v)
+		case "stale-after":
+			c.StaleAfter, c.StaleAfterScraper, err = ParseStaleAfter(v)
+		case "admin-tokens":
+			c.AdminTokens, err = ParseAdminTokens(v)
+		case "admin-users":
+			c.AdminUsers, err = ParseAdminUsers(v)
+		case "fetch-articles":
+			c.Articles.Enabled, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for -%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	return d.Abs(), err
+}
+
+// Store holds the current config, it is replaced when the config file is
+// reloaded.
+type Store struct {
+	p atomic.Pointer[Config]
+}
+
+func NewStore(c Config) *Store {
+	s := &Store{}
+	s.Set(c)
+	return s
+}
+
+func (s *Store) Get() Config {
+	return *s.p.Load()
+}
+
+func (s *Store) Set(c Config) {
+	s.p.Store(&c)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to a temp file, empty loads without config file
+		file      string
+		overrides map[string]string
+		// want modifies the default config to the expected one
+		want    func(c *Config)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: func(c *Config) {},
+		},
+		{
+			name: "file replaces defaults",
+			file: `
+listen: ":8080"
+scrape_interval: 5m
+cookie_secure: false
+scrapers: [hn, lobsters]
+hn:
+  page_size: 50
+articles:
+  enabled: true
+`,
+			want: func(c *Config) {
+				c.Listen = ":8080"
+				c.ScrapeInterval = 5 * time.Minute
+				c.CookieSecure = false
+				c.Scrapers = []string{"hn", "lobsters"}
+				c.HNPageSize = 50
+				c.Articles.Enabled = true
+			},
+		},
+		{
+			name:      "overrides without file",
+			overrides: map[string]string{"listen": ":9090", "scrapers": "hn, lobsters", "cookie-insecure": "true"},
+			want: func(c *Config) {
+				c.Listen = ":9090"
+				c.Scrapers = []string{"hn", "lobsters"}
+				c.CookieSecure = false
+			},
+		},
+		{
+			name: "overrides take precedence over the file",
+			file: `
+listen: ":8080"
+scrape_interval: 5m
+scrapers: [lobsters]
+articles:
+  enabled: true
+  batch_size: 20
+`,
+			overrides: map[string]string{"listen": ":9090", "scrape-interval": "10m", "fetch-articles": "false"},
+			want: func(c *Config) {
+				c.Listen = ":9090"
+				c.ScrapeInterval = 10 * time.Minute
+				c.Scrapers = []string{"lobsters"}
+				c.Articles.BatchSize = 20
+			},
+		},
+		{
+			name: "override replaces lists of the file",
+			file: `
+feeds:
+  - name: lwn
+    url: https://lwn.net/headlines/rss
+  - name: blog
+    url: https://example.com/feed
+`,
+			overrides: map[string]string{"feeds": "other=https://example.org/feed"},
+			want: func(c *Config) {
+				c.Feeds = []FeedConfig{{Name: "other", URL: "https://example.org/feed"}}
+			},
+		},
+		{
+			name:    "unknown field",
+			file:    "scrape_intervall: 5m\n",
+			wantErr: "field scrape_intervall not found",
+		},
+		{
+			name: "invalid values are all reported",
+			file: `
+scrape_interval: -1m
+articles:
+  batch_size: 0
+`,
+			wantErr: "scrape_interval: must not be negative, got -1m0s\narticles.batch_size: must be positive, got 0",
+		},
+		{
+			name:      "invalid override",
+			file:      "listen: \":8080\"\n",
+			overrides: map[string]string{"scrape-timeout": "soon"},
+			wantErr:   "invalid value for -scrape-timeout",
+		},
+		{
+			name:      "unknown override",
+			overrides: map[string]string{"log-level": "debug"},
+			wantErr:   "invalid value for -log-level: unknown setting",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Load(path, tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), nil)
+	if err == nil || !strings.Contains(err.Error(), "could not read config file") {
+		t.Fatalf("got error %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+)
+
+// eventsKeepAlive is how often a comment is sent to keep idle connections
+// from being closed by proxies
+const eventsKeepAlive = 30 * time.Second
+
+// broker tells the connected readers that new stories were scraped.
+type broker struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{subs: map[chan struct{}]struct{}{}}
+}
+
+func (b *broker) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish notifies all subscribers, subscribers that have not handled the
+// previous notification yet are skipped, they query all new stories anyway.
+func (b *broker) publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StoriesEvent is sent to the reader when new stories were scraped.
+type StoriesEvent struct {
+	// HTML are the table rows of the new stories, newest first
+	HTML string `json:"html"`
+	// Latest is the id to mark as read to mark the new stories as read too
+	Latest int64 `json:"latest"`
+	// Unread is the number of new unread stories
+	Unread int `json:"unread"`
+}
+
+func storiesEvent(c echo.Context, db *model.DB, s model.Session, since int64) (StoriesEvent, error) {
+	ctx := c.Request().Context()
+	stories, err := listStories(ctx, db, s, since+1)
+	if err != nil {
+		return StoriesEvent{}, err
+	}
+	ev := StoriesEvent{Latest: since, Unread: unreadCount(stories, s.LastRead)}
+	b := bytes.Buffer{}
+	for _, g := range stories {
+		if err := views.Story(g, s.LastRead, s.RewriteLinks).Render(ctx, &b); err != nil {
+			return StoriesEvent{}, err
+		}
+		ev.Latest = max(ev.Latest, g.LatestID())
+	}
+	ev.HTML = b.String()
+	return ev, nil
+}
+
+// registerEvents adds the /events endpoint streaming new stories as
+// Server-Sent Events. since is the latest story the reader has, browsers send
+// the id of the last event when reconnecting.
+func registerEvents(app *echo.Echo, db *model.DB, conf *config.Store, events *broker) {
+	app.GET("/events", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		since, err := queryInt(c, "since", 0)
+		if err != nil {
+			return err
+		}
+		if id, err := strconv.ParseInt(c.Request().Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			since = max(since, id)
+		}
+
+		// the session is resolved once, before the headers are sent, as
+		// moving a legacy cookie onto a new session sets a cookie
+		var s model.Session
+		found := false
+		if token := c.QueryParam("session"); token != "" {
+			s, found, err = getSession(ctx, db, token)
+			if err == nil && !found {
+				return echo.NewHTTPError(http.StatusNotFound, "session not found")
+			}
+		} else {
+			s, found, err = sessionFromCookie(c, db, conf)
+		}
+		if err != nil {
+			return err
+		}
+		// stored sessions are read again on every update, filters could
+		// have changed
+		reloadSession := func() (model.Session, error) {
+			if !found {
+				return s, nil
+			}
+			reloaded, ok, err := getSession(ctx, db, s.Token)
+			if err != nil || !ok {
+				return s, err
+			}
+			return reloaded, nil
+		}
+
+		notifications, unsubscribe := events.subscribe()
+		defer unsubscribe()
+
+		w := c.Response()
+		w.Header().Set(echo.HeaderContentType, "text/event-stream")
+		w.Header().Set(echo.HeaderCacheControl, "no-cache")
+		w.Header().Set(echo.HeaderConnection, "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+				w.Flush()
+			case <-notifications:
+				s, err := reloadSession()
+				if err != nil {
+					return err
+				}
+				ev, err := storiesEvent(c, db, s, since)
+				if err != nil {
+					return err
+				}
+				if ev.Latest == since {
+					continue
+				}
+				since = ev.Latest
+				data, err := json.Marshal(ev)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: stories\ndata: %s\n\n", ev.Latest, data); err != nil {
+					return nil
+				}
+				w.Flush()
+			}
+		}
+	})
+}
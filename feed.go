@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+)
+
+// max number of stories in the atom and rss feeds
+const feedSize = 100
+
+// muted stories are skipped after the query, so up to this many pages of
+// feedSize stories are read to fill the feed
+const feedMaxPages = 10
+
+type feedRenderer func(views.FeedInfo, []model.Story) ([]byte, error)
+
+// StoryFilter restricts stories to the given scrapers and types, empty
+// lists match everything.
+type StoryFilter struct {
+	Scrapers []string
+	Types    []string
+}
+
+// filterFromQuery reads the scraper and type query parameters, both can be
+// given multiple times or as comma separated list.
+func filterFromQuery(c echo.Context) StoryFilter {
+	values := func(name string) []string {
+		l := []string{}
+		for _, v := range c.QueryParams()[name] {
+			for _, e := range strings.Split(v, ",") {
+				if e = strings.TrimSpace(e); e != "" {
+					l = append(l, e)
+				}
+			}
+		}
+		return l
+	}
+	return StoryFilter{
+		Scrapers: values("scraper"),
+		Types:    values("type"),
+	}
+}
+
+func feedHandler(db *model.DB, contentType string, render feedRenderer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filter := filterFromQuery(c)
+		ctx := c.Request().Context()
+		queries := db.Queries()
+		muter, err := model.LoadMuter(ctx, queries)
+		if err != nil {
+			return err
+		}
+
+		// duplicates are left out by the query, unless the story they
+		// duplicate is filtered out
+		stories := []model.Story{}
+		before := int64(math.MaxInt64)
+		for range feedMaxPages {
+			page, err := queries.ListStories(ctx, model.ListStoriesParams{
+				Before:     before,
+				Scrapers:   strings.Join(filter.Scrapers, ","),
+				Types:      strings.Join(filter.Types, ","),
+				MaxResults: feedSize,
+			})
+			if err != nil {
+				return err
+			}
+			for _, s := range page {
+				if len(stories) < feedSize && !muter.Muted(&s) {
+					stories = append(stories, s)
+				}
+			}
+			if len(stories) >= feedSize || len(page) < feedSize {
+				break
+			}
+			before = page[len(page)-1].ID
+		}
+
+		etag, lastModified := feedCacheKeys(c.Request().URL.Path, filter, stories)
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		c.Response().Header().Set("ETag", etag)
+		if notModified(c.Request(), etag, lastModified) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		base := c.Scheme() + "://" + c.Request().Host
+		b, err := render(views.FeedInfo{
+			Title:   "serializer.go",
+			SiteURL: base + "/",
+			SelfURL: base + c.Request().URL.RequestURI(),
+			Updated: lastModified,
+		}, stories)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, contentType, b)
+	}
+}
+
+// feedCacheKeys derives the ETag from the path, filter and the state of
+// all stories in the feed, Last-Modified is the latest update of a story.
+func feedCacheKeys(path string, filter StoryFilter, stories []model.Story) (string, time.Time) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%v|%v", path, filter.Scrapers, filter.Types)
+	lastModified := time.Unix(0, 0)
+	for _, s := range stories {
+		fmt.Fprintf(h, "|%d:%d", s.ID, s.UpdatedAt.UnixNano())
+		if s.UpdatedAt.After(lastModified) {
+			lastModified = s.UpdatedAt
+		}
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum64()), lastModified.UTC().Truncate(time.Second)
+}
+
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == etag || t == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
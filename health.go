@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/model"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	statusUp   = "UP"
+	statusDown = "DOWN"
+)
+
+type DBHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ScraperHealth struct {
+	Scraper                 string     `json:"scraper"`
+	Status                  string     `json:"status"`
+	LastRun                 *time.Time `json:"last_run,omitempty"`
+	LastSuccess             *time.Time `json:"last_success,omitempty"`
+	LastError               string     `json:"last_error,omitempty"`
+	SecondsSinceLastSuccess int64      `json:"seconds_since_last_success"`
+	StaleAfterSeconds       int64      `json:"stale_after_seconds"`
+}
+
+type Health struct {
+	Status   string          `json:"status"`
+	DB       DBHealth        `json:"db"`
+	Scrapers []ScraperHealth `json:"scrapers"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func checkHealth(ctx context.Context, db *model.DB, scheduler *job.Scheduler) Health {
+	h := Health{Status: statusUp, DB: DBHealth{Status: statusUp}, Scrapers: []ScraperHealth{}}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		h.Status = statusDown
+		h.DB = DBHealth{Status: statusDown, Error: err.Error()}
+	}
+
+	for _, st := range scheduler.Status() {
+		sh := ScraperHealth{
+			Scraper:                 st.Scraper,
+			Status:                  statusUp,
+			LastRun:                 optionalTime(st.LastRun),
+			LastSuccess:             optionalTime(st.LastSuccess),
+			LastError:               st.LastError,
+			SecondsSinceLastSuccess: int64(st.SinceSuccess.Seconds()),
+			StaleAfterSeconds:       int64(st.StaleAfter.Seconds()),
+		}
+		if st.Stale {
+			sh.Status = statusDown
+		}
+		h.Scrapers = append(h.Scrapers, sh)
+	}
+	return h
+}
+
+// registerHealth adds the health checks. /livez only tells that the process
+// serves requests, restarting it would not fix a DB or source outage. /readyz
+// fails if the DB can't be reached or a scraper has not succeeded for too long.
+func registerHealth(app *echo.Echo, db *model.DB, scheduler *job.Scheduler) {
+	livez := func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": statusUp})
+	}
+	app.GET("/livez", livez)
+	// kept for existing health checks
+	app.GET("/healthz", livez)
+
+	app.GET("/readyz", func(c echo.Context) error {
+		h := checkHealth(c.Request().Context(), db, scheduler)
+		for _, sh := range h.Scrapers {
+			if sh.Status == statusDown {
+				h.Status = statusDown
+			}
+		}
+		if h.Status != statusUp {
+			return c.JSON(http.StatusServiceUnavailable, h)
+		}
+		return c.JSON(http.StatusOK, h)
+	})
+}
+
+// healthcheckURL returns the url of path on the server listening on addr,
+// for the healthcheck command run inside the container.
+func healthcheckURL(addr, path string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = "", addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path
+}
+
+// runHealthcheck fails if the server does not answer /livez, or with -ready
+// if /readyz reports it as not ready.
+func runHealthcheck(conf config.Config, args []string) error {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	ready := flags.Bool("ready", false, "check /readyz instead of /livez, fails if the DB is unreachable or a scraper is stale")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	path := "/livez"
+	if *ready {
+		path = "/readyz"
+	}
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(healthcheckURL(conf.Listen, path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy, got status %d from %s", resp.StatusCode, path)
+	}
+	return nil
+}
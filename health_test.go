@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/floj/serializer-go/config"
+)
+
+func TestRunHealthcheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/livez":
+			w.WriteHeader(http.StatusOK)
+		case "/readyz":
+			// e.g. a stalled scheduler
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	conf := config.Config{Listen: srv.Listener.Addr().String()}
+
+	if err := runHealthcheck(conf, nil); err != nil {
+		t.Errorf("live: %v", err)
+	}
+	if err := runHealthcheck(conf, []string{"-ready"}); err == nil {
+		t.Error("ready: expected an error for a server that is not ready")
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":3000", "http://localhost:3000/readyz"},
+		{"3000", "http://localhost:3000/readyz"},
+		{"0.0.0.0:3000", "http://localhost:3000/readyz"},
+		{"[::]:3000", "http://localhost:3000/readyz"},
+		{"127.0.0.1:8080", "http://127.0.0.1:8080/readyz"},
+	}
+	for _, tt := range tests {
+		if got := healthcheckURL(tt.addr, "/readyz"); got != tt.want {
+			t.Errorf("healthcheckURL(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -1,48 +1,276 @@
 package job
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/metrics"
 	"github.com/floj/serializer-go/model"
 	"github.com/floj/serializer-go/scraper"
 )
 
-func Start(db *sql.DB, conf config.Config, scrapers ...scraper.Scraper) (func(func(Result, error) error) error, func()) {
-	// restrict interval to be at max every minute
-	interval := max(conf.ScrapeInterval, time.Minute)
-	if !conf.ScrapeEnabled() {
-		interval = time.Hour * 24
+// scrapers are run at most once a minute, regardless of their schedule
+const minScrapeInterval = time.Minute
+
+// runs older than this are deleted from the run log
+const runRetention = 7 * 24 * time.Hour
+
+// what started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// job runs a single scraper on its own schedule. mu keeps scheduled and
+// manually triggered runs of the same scraper from overlapping, it is passed on
+// to the job replacing this one on reload.
+type job struct {
+	scraper    scraper.Scraper
+	schedule   scraper.Schedule
+	staleAfter time.Duration
+	recent     recentWindow
+	mu         *sync.Mutex
+
+	// guards the fields below, they are read while the scraper runs
+	statusMu    sync.Mutex
+	startedAt   time.Time
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+// scheduleFor returns the schedule of the scraper: the configured one, else the
+// one the scraper declares, else the default.
+func scheduleFor(conf config.Config, scr scraper.Scraper) scraper.Schedule {
+	sched := scraper.Schedule{Interval: conf.ScrapeInterval, Timeout: conf.ScrapeTimeout}
+	if s, ok := scr.(scraper.Scheduled); ok {
+		declared := s.Schedule()
+		sched.Interval = cmp.Or(declared.Interval, sched.Interval)
+		sched.Timeout = cmp.Or(declared.Timeout, sched.Timeout)
+		sched.Jitter = cmp.Or(declared.Jitter, sched.Jitter)
 	}
+	if c, ok := conf.Schedules[scr.Name()]; ok {
+		sched.Interval = cmp.Or(c.Interval, sched.Interval)
+		sched.Timeout = cmp.Or(c.Timeout, sched.Timeout)
+		sched.Jitter = cmp.Or(c.Jitter, sched.Jitter)
+	}
+	sched.Interval = max(sched.Interval, minScrapeInterval)
+	return sched
+}
 
-	ticker := time.NewTicker(interval)
-	quit := make(chan struct{})
-	mu := &sync.Mutex{}
+// staleAfterFor returns how long the scraper may go without a successful run,
+// by default three missed runs.
+func staleAfterFor(conf config.Config, scr scraper.Scraper, sched scraper.Schedule) time.Duration {
+	if d, ok := conf.StaleAfterScraper[scr.Name()]; ok {
+		return d
+	}
+	return cmp.Or(conf.StaleAfter, 3*(sched.Interval+sched.Jitter)+sched.Timeout)
+}
 
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				if conf.ScrapeEnabled() {
-					r, err := runScrape(db, mu, conf, scrapers...)
-					slog.Info("scrape finished", "result", r, "err", err)
-				}
-			case <-quit:
-				ticker.Stop()
-				return
-			}
+// recentWindow selects the stories that left the front page but are still
+// updated: those not updated for updateAfter and younger than maxAge.
+type recentWindow struct {
+	updateAfter time.Duration
+	maxAge      time.Duration
+}
+
+// Scheduler runs the scrapers.
+type Scheduler struct {
+	db     *model.DB
+	notify func(Result)
+
+	// guards the fields below, they are replaced on reload
+	mu      sync.Mutex
+	jobs    []*job
+	enabled bool
+	quit    chan struct{}
+}
+
+// Start runs each scraper periodically on its own schedule. notify is called
+// after every run that found new stories.
+func Start(db *model.DB, conf config.Config, notify func(Result), scrapers ...scraper.Scraper) *Scheduler {
+	s := &Scheduler{db: db, notify: notify}
+	s.Reload(conf, scrapers...)
+	return s
+}
+
+// Reload replaces the scrapers and their schedules. Runs in progress are
+// finished, scrapers that are kept keep their status.
+func (s *Scheduler) Reload(conf config.Config, scrapers ...scraper.Scraper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit != nil {
+		close(s.quit)
+	}
+	s.quit = make(chan struct{})
+	s.enabled = conf.ScrapeEnabled()
+
+	previous := map[string]*job{}
+	for _, j := range s.jobs {
+		previous[j.scraper.Name()] = j
+	}
+	now := time.Now()
+	jobs := make([]*job, 0, len(scrapers))
+	for _, scr := range scrapers {
+		sched := scheduleFor(conf, scr)
+		j := &job{
+			scraper:    scr,
+			schedule:   sched,
+			staleAfter: staleAfterFor(conf, scr, sched),
+			recent:     recentWindow{updateAfter: conf.RecentUpdateAfter, maxAge: conf.RecentMaxAge},
+			mu:         &sync.Mutex{},
+			startedAt:  now,
+		}
+		if p, ok := previous[scr.Name()]; ok {
+			j.mu = p.mu
+			p.statusMu.Lock()
+			j.startedAt, j.lastRun, j.lastSuccess, j.lastError = p.startedAt, p.lastRun, p.lastSuccess, p.lastError
+			p.statusMu.Unlock()
 		}
-	}()
-	return func(f func(Result, error) error) error {
-			return f(runScrape(db, mu, conf, scrapers...))
-		}, func() {
-			quit <- struct{}{}
+		jobs = append(jobs, j)
+		if s.enabled {
+			slog.Info("scheduling scraper", "scraper", scr.Name(), "interval", sched.Interval, "timeout", sched.Timeout, "jitter", sched.Jitter, "staleAfter", j.staleAfter)
+			go j.loop(s.db, s.notify, s.quit)
 		}
+	}
+	s.jobs = jobs
+}
+
+func (s *Scheduler) currentJobs() ([]*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs, s.enabled
+}
+
+// Sources returns the names of the scrapers.
+func (s *Scheduler) Sources() []string {
+	jobs, _ := s.currentJobs()
+	sources := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		sources = append(sources, j.scraper.Name())
+	}
+	return sources
+}
+
+// Trigger runs all scrapers at once and hands the merged result to f.
+func (s *Scheduler) Trigger(f func(Result, error) error) error {
+	jobs, _ := s.currentJobs()
+	return f(runScrape(s.db, s.notify, jobs))
+}
+
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.quit)
+	s.quit = nil
+}
+
+// Status is the health of a scraper.
+type Status struct {
+	Scraper     string
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+	// SinceSuccess is the time since the last successful run, or since the
+	// start if there was none yet
+	SinceSuccess time.Duration
+	StaleAfter   time.Duration
+	// Stale is set if the scraper is scheduled and has not succeeded within
+	// StaleAfter
+	Stale bool
+}
+
+// Status reports the health of all scrapers.
+func (s *Scheduler) Status() []Status {
+	jobs, enabled := s.currentJobs()
+	now := time.Now()
+	status := make([]Status, 0, len(jobs))
+	for _, j := range jobs {
+		j.statusMu.Lock()
+		st := Status{
+			Scraper:      j.scraper.Name(),
+			LastRun:      j.lastRun,
+			LastSuccess:  j.lastSuccess,
+			LastError:    j.lastError,
+			SinceSuccess: now.Sub(latest(j.startedAt, j.lastSuccess)),
+			StaleAfter:   j.staleAfter,
+		}
+		j.statusMu.Unlock()
+		st.Stale = enabled && st.StaleAfter > 0 && st.SinceSuccess > st.StaleAfter
+		status = append(status, st)
+	}
+	return status
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (j *job) loop(db *model.DB, notify func(Result), quit <-chan struct{}) {
+	for {
+		wait := j.schedule.Interval
+		if j.schedule.Jitter > 0 {
+			wait += rand.N(j.schedule.Jitter)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			r := j.run(db, notify, TriggerSchedule)
+			slog.Info("scrape finished", "scraper", j.scraper.Name(), "result", r, "err", r.Err())
+		case <-quit:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (j *job) run(db *model.DB, notify func(Result), trigger string) Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ctx := context.Background()
+	if j.schedule.Timeout > 0 {
+		tctx, cancel := context.WithTimeout(ctx, j.schedule.Timeout)
+		ctx = tctx
+		defer cancel()
+	}
+
+	slog.Info("running scraper", "scraper", j.scraper.Name())
+	start := time.Now()
+	result := runScraper(ctx, j.scraper, db.Queries(), j.recent)
+	result.Errors = len(result.err)
+	metrics.ObserveScrape(j.scraper.Name(), time.Since(start), result.New, result.Updated, result.Recent, result.Errors)
+
+	logRun(db, j.scraper.Name(), trigger, start, result)
+
+	j.statusMu.Lock()
+	j.lastRun = start
+	if result.fetched {
+		j.lastSuccess = start
+	}
+	j.lastError = ""
+	if err := result.Err(); err != nil {
+		j.lastError = err.Error()
+	}
+	j.statusMu.Unlock()
+
+	// stories found before an error are stored, so readers are notified anyway
+	if result.New > 0 {
+		notify(result)
+	}
+	return result
 }
 
 type Result struct {
@@ -51,6 +279,9 @@ type Result struct {
 	Recent  int
 	Errors  int
 	err     []error
+	// fetched is set if the scraper could fetch its listing, errors for
+	// single stories don't make a run fail
+	fetched bool
 }
 
 func (r Result) Merge(other Result) Result {
@@ -62,32 +293,74 @@ func (r Result) Merge(other Result) Result {
 	return r
 }
 
-func runScrape(db *sql.DB, mu *sync.Mutex, conf config.Config, scrapers ...scraper.Scraper) (Result, error) {
-	mu.Lock()
-	defer mu.Unlock()
+// ErrorMessages returns the messages of the errors of the run.
+func (r Result) ErrorMessages() []string {
+	msgs := make([]string, 0, len(r.err))
+	for _, err := range r.err {
+		// one line per error in the run log
+		msgs = append(msgs, strings.ReplaceAll(err.Error(), "\n", " "))
+	}
+	return msgs
+}
+
+// Err joins the errors of the run, nil if there were none.
+func (r Result) Err() error {
+	return errors.Join(r.err...)
+}
+
+// runScrape runs all scrapers concurrently, so a slow source does not delay
+// the others.
+func runScrape(db *model.DB, notify func(Result), jobs []*job) (Result, error) {
+	results := make([]Result, len(jobs))
+	wg := sync.WaitGroup{}
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = j.run(db, notify, TriggerManual)
+		}()
+	}
+	wg.Wait()
 
 	result := Result{}
-	ctx := context.Background()
-	if conf.HasScrapeTimeout() {
-		tctx, cancel := context.WithTimeout(ctx, conf.ScrapeTimeout)
-		ctx = tctx
-		defer cancel()
+	for i, r := range results {
+		// name the source, the merged errors don't tell otherwise
+		for k, err := range r.err {
+			r.err[k] = fmt.Errorf("%s: %w", jobs[i].scraper.Name(), err)
+		}
+		result = result.Merge(r)
 	}
+	return result, result.Err()
+}
 
-	queries := model.New(db)
+// logRun persists the run, so failing sources can be debugged later.
+func logRun(db *model.DB, scraperName, trigger string, start time.Time, result Result) {
+	// the scrape context might have run out of time already
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	for _, scraper := range scrapers {
-		slog.Info("running scraper", "scraper", scraper.Name())
-		res := runScraper(ctx, scraper, queries)
-		result = result.Merge(res)
+	queries := db.Queries()
+	_, err := queries.CreateScrapeRun(ctx, model.CreateScrapeRunParams{
+		Scraper:      scraperName,
+		Trigger:      trigger,
+		StartedAt:    start,
+		FinishedAt:   time.Now(),
+		NewCount:     int32(result.New),
+		UpdatedCount: int32(result.Updated),
+		RecentCount:  int32(result.Recent),
+		ErrorCount:   int32(result.Errors),
+		Errors:       strings.Join(result.ErrorMessages(), "\n"),
+	})
+	if err != nil {
+		slog.Error("could not log scrape run", "scraper", scraperName, "err", err)
+		return
 	}
-	if len(result.err) > 0 {
-		return result, errors.Join(result.err...)
+	if err := queries.DeleteScrapeRunsBefore(ctx, start.Add(-runRetention)); err != nil {
+		slog.Error("could not delete old scrape runs", "err", err)
 	}
-	return result, nil
 }
 
-func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries) Result {
+func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries, recent recentWindow) Result {
 	result := Result{}
 
 	items, err := scr.FetchItems(ctx)
@@ -95,6 +368,7 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 		result.err = append(result.err, err)
 		return result
 	}
+	result.fetched = true
 
 	slog.Info("processig stories", "num", len(items))
 	for _, itm := range items {
@@ -114,13 +388,14 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 		if len(existing) > 0 {
 			for _, story := range existing {
 				updatedStory, err := queries.UpdateStory(ctx, model.UpdateStoryParams{
-					Title:       itm.Title,
-					Url:         itm.Url,
-					Score:       itm.Score,
-					NumComments: itm.NumComments,
-					Type:        itm.Type,
-					LastSeenFp:  now,
-					ID:          story.ID,
+					Title:        itm.Title,
+					Url:          itm.Url,
+					CanonicalUrl: model.CanonicalURL(itm.Url),
+					Score:        itm.Score,
+					NumComments:  itm.NumComments,
+					Type:         itm.Type,
+					LastSeenFp:   now,
+					ID:           story.ID,
 				})
 				if err != nil {
 					slog.Error("failed to updated story", "story", story, "err", err)
@@ -133,16 +408,19 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 			continue
 		}
 
+		canonicalURL := model.CanonicalURL(itm.Url)
 		story, err := queries.CreateStory(ctx, model.CreateStoryParams{
-			RefID:       itm.RefID,
-			Url:         itm.Url,
-			By:          itm.By,
-			PublishedAt: itm.PublishedAt,
-			Title:       itm.Title,
-			Type:        itm.Type,
-			Score:       itm.Score,
-			NumComments: itm.NumComments,
-			Scraper:     itm.Scraper,
+			RefID:        itm.RefID,
+			Url:          itm.Url,
+			By:           itm.By,
+			PublishedAt:  itm.PublishedAt,
+			Title:        itm.Title,
+			Type:         itm.Type,
+			Score:        itm.Score,
+			NumComments:  itm.NumComments,
+			Scraper:      itm.Scraper,
+			CanonicalUrl: canonicalURL,
+			DuplicateOf:  findOriginal(ctx, queries, itm.Scraper, canonicalURL, now),
 		})
 
 		if err != nil {
@@ -158,8 +436,8 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 	now := time.Now()
 	stories, err := queries.FindRecentForUpdate(ctx, model.FindRecentForUpdateParams{
 		Scraper:   scr.Name(),
-		UpdatedAt: now.Add(-15 * time.Minute),
-		CreatedAt: now.Add(-24 * time.Hour),
+		UpdatedAt: now.Add(-recent.updateAfter),
+		CreatedAt: now.Add(-recent.maxAge),
 	})
 
 	if err != nil {
@@ -170,6 +448,10 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 
 	for _, story := range stories {
 		itm, found, err := scr.FetchItem(ctx, story.RefID)
+		if errors.Is(err, scraper.ErrSkipItem) {
+			slog.Debug("skipping recent story", "story", story)
+			continue
+		}
 		if err != nil {
 			slog.Error("failed to fetch recent story", "story", story, "err", err)
 			result.err = append(result.err, err)
@@ -189,13 +471,14 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 
 		slog.Debug("updating recent story", "story", story)
 		updatedStory, err := queries.UpdateStory(ctx, model.UpdateStoryParams{
-			Title:       itm.Title,
-			Url:         itm.Url,
-			Score:       itm.Score,
-			NumComments: itm.NumComments,
-			Type:        story.Type,
-			ID:          story.ID,
-			LastSeenFp:  story.LastSeenFp,
+			Title:        itm.Title,
+			Url:          itm.Url,
+			CanonicalUrl: model.CanonicalURL(itm.Url),
+			Score:        itm.Score,
+			NumComments:  itm.NumComments,
+			Type:         story.Type,
+			ID:           story.ID,
+			LastSeenFp:   story.LastSeenFp,
 		})
 
 		if err != nil {
@@ -205,8 +488,62 @@ func runScraper(ctx context.Context, scr scraper.Scraper, queries *model.Queries
 			result.Recent++
 			slog.Debug("updated recent story", "story", updatedStory)
 		}
+
+		if c, ok := scr.(scraper.Commented); ok {
+			if err := snapshotComments(ctx, queries, c, story); err != nil {
+				slog.Error("failed to snapshot comments", "story", story, "err", err)
+				result.err = append(result.err, err)
+			}
+		}
 	}
 	slog.Info("processed stories", "new", result.New, "updated", result.Updated, "recent", result.Recent, "err", result.Errors)
 
 	return result
 }
+
+// snapshotComments stores the comment tree of a story that left the front
+// page, once, so the discussion can be read even if the source is down later.
+func snapshotComments(ctx context.Context, queries *model.Queries, c scraper.Commented, story model.Story) error {
+	exists, err := queries.HasCommentSnapshot(ctx, story.ID)
+	if err != nil || exists {
+		return err
+	}
+	comments, err := c.FetchComments(ctx, story.RefID)
+	if err != nil {
+		return fmt.Errorf("could not fetch comments of story %d: %w", story.ID, err)
+	}
+	params, err := model.NewCommentSnapshot(story.ID, comments)
+	if err != nil {
+		return err
+	}
+	if _, err := queries.SaveCommentSnapshot(ctx, params); err != nil {
+		return fmt.Errorf("could not save comments of story %d: %w", story.ID, err)
+	}
+	slog.Debug("saved comment snapshot", "story", story.ID, "comments", params.NumComments)
+	return nil
+}
+
+// stories linking to the same article are only considered duplicates if they
+// were collected within this window
+const duplicateWindow = 7 * 24 * time.Hour
+
+// findOriginal looks up an earlier story from another scraper linking to the
+// same article.
+func findOriginal(ctx context.Context, queries *model.Queries, scraperName, canonicalURL string, now time.Time) sql.NullInt64 {
+	if canonicalURL == "" {
+		return sql.NullInt64{}
+	}
+	original, err := queries.FindOriginalByCanonicalURL(ctx, model.FindOriginalByCanonicalURLParams{
+		CanonicalUrl: canonicalURL,
+		Scraper:      scraperName,
+		CreatedAt:    now.Add(-duplicateWindow),
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("could not look up original story", "url", canonicalURL, "err", err)
+		}
+		return sql.NullInt64{}
+	}
+	slog.Debug("found original story", "original", original.ID, "url", canonicalURL)
+	return sql.NullInt64{Int64: original.ID, Valid: true}
+}
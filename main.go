@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -12,14 +13,21 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/floj/serializer-go/article"
 	"github.com/floj/serializer-go/assets"
 	"github.com/floj/serializer-go/config"
 	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/metrics"
 	"github.com/floj/serializer-go/model"
 	"github.com/floj/serializer-go/scraper"
+	"github.com/floj/serializer-go/scraper/feed"
 	"github.com/floj/serializer-go/scraper/hackernews"
+	"github.com/floj/serializer-go/scraper/lobsters"
+	"github.com/floj/serializer-go/scraper/reddit"
+	"github.com/floj/serializer-go/scraper/resilient"
 	"github.com/floj/serializer-go/views"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -35,12 +43,50 @@ func envOrDefault(name, def string) string {
 	return def
 }
 
+// configFlags maps the flags overriding the config file to their env var.
+var configFlags = map[string]string{}
+
+func configFlag(name, env, def, usage string) {
+	configFlags[name] = env
+	flag.String(name, def, usage)
+}
+
+// flagOverrides returns the flags given on the command line and the env vars
+// that are set, keyed by flag name. Flags take precedence over env vars.
+func flagOverrides(fs *flag.FlagSet, getenv func(string) string) map[string]string {
+	overrides := map[string]string{}
+	for name, env := range configFlags {
+		if v := getenv(env); env != "" && v != "" {
+			overrides[name] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := configFlags[f.Name]; ok {
+			overrides[f.Name] = f.Value.String()
+		}
+	})
+	return overrides
+}
+
 func main() {
-	//dbFile := flag.String("database-file", envOrDefault("DATABASE_FILE", "serializer.db"), "path to the database file")
-	dbURL := flag.String("db-uri", envOrDefault("DB_URI", ""), "connection uri for the DB")
-	scrapeInterval := flag.String("scrape-interval", envOrDefault("SCRAPE_INTERVAL", "1m"), "how often to scrape, set to 0 to disable scrape job")
-	scrapeTimeout := flag.String("scrape-timeout", envOrDefault("SCRAPE_TIMEOUT", "1m"), "max time one scrape job is allowed to run set to 0 to for no limit")
-	cookieInsecure := flag.Bool("cookie-insecure", false, "set secure flag on cookie")
+	d := config.Default()
+	configFile := flag.String("config", envOrDefault("CONFIG_FILE", ""), "path to a YAML config file, flags and env vars override its settings")
+	configFlag("listen", "LISTEN", d.Listen, "address to listen on")
+	configFlag("db-uri", "DB_URI", "", "connection uri for the DB, postgres://... or sqlite://path/to/file.db")
+	configFlag("scrape-interval", "SCRAPE_INTERVAL", d.ScrapeInterval.String(), "how often to scrape, set to 0 to disable scrape job")
+	configFlag("scrape-timeout", "SCRAPE_TIMEOUT", d.ScrapeTimeout.String(), "max time one scrape job is allowed to run set to 0 to for no limit")
+	configFlag("scrapers", "SCRAPERS", strings.Join(d.Scrapers, ","), "comma separated list of built-in scrapers to run (hn, lobsters)")
+	configFlag("hn-backend", "HN_BACKEND", d.HNBackend, "API used to scrape HN (algolia, firebase), the other one is used as fallback")
+	configFlag("feeds", "FEEDS", "", "comma separated list of feeds to scrape, each as name=url or name=url|icon")
+	configFlag("subreddits", "SUBREDDITS", "", "comma separated list of subreddits to scrape, each as name or name:minscore")
+	configFlag("schedules", "SCHEDULES", "", "comma separated list of per scraper schedules, each as name=interval[:timeout[:jitter]], e.g. feed:lwn=30m::5m")
+	configFlag("stale-after", "STALE_AFTER", "", "how long a scraper may go without success before /readyz fails, as default and/or per scraper, e.g. 30m,feed:lwn=12h (default 3 missed runs)")
+	configFlag("admin-tokens", "ADMIN_TOKENS", "", "comma separated list of bearer tokens granting access to /admin")
+	configFlag("admin-users", "ADMIN_USERS", "", "comma separated list of basic auth credentials granting access to /admin, each as user:password")
+	configFlags["cookie-insecure"] = ""
+	flag.Bool("cookie-insecure", false, "set secure flag on cookie")
+	configFlags["fetch-articles"] = "FETCH_ARTICLES"
+	flag.Bool("fetch-articles", false, "fetch the articles of link stories in the background to show their reading time and text")
 	logLevel := flag.String("log-level", "info", "log level (debug, info, warn, error)")
 	flag.Parse()
 
@@ -62,25 +108,41 @@ func main() {
 		panic("invalid log level: " + *logLevel)
 	}
 
-	conf, err := config.Create(*dbURL, *scrapeInterval, *scrapeTimeout, !*cookieInsecure)
+	overrides := flagOverrides(flag.CommandLine, os.Getenv)
+	conf, err := config.Load(*configFile, overrides)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 
 	slog.SetDefault(slog.New(
 		tint.NewHandler(logDest, tintOpts),
 	))
 
-	if err := run(conf); err != nil {
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		err = run(conf, func() (config.Config, error) {
+			return config.Load(*configFile, overrides)
+		})
+	case "migrate":
+		err = runMigrate(conf, flag.Args()[1:])
+	case "check-config":
+		fmt.Println("config is valid")
+	case "healthcheck":
+		err = runHealthcheck(conf, flag.Args()[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
-func unreadCount(stories []model.Story, last int64) int {
+func unreadCount(stories []model.StoryGroup, last int64) int {
 	i := 0
 	for _, s := range stories {
-		if s.ID <= last {
+		if s.ID <= last || s.Muted {
 			continue
 		}
 		i++
@@ -88,7 +150,8 @@ func unreadCount(stories []model.Story, last int64) int {
 	return i
 }
 
-func run(conf config.Config) error {
+// run serves the app, load reads the config again on SIGHUP.
+func run(conf config.Config, load func() (config.Config, error)) error {
 	if conf.DBURI == "" {
 		return fmt.Errorf("db-uri is mandatory but was not set")
 	}
@@ -99,16 +162,35 @@ func run(conf config.Config) error {
 	}
 	defer db.Close()
 
-	scrapers, err := loadScrapers(conf)
+	store := config.NewStore(conf)
+	transport := resilient.NewTransport(metrics.InstrumentRoundTripper(nil), resilient.Options{})
+	httpc := &http.Client{Transport: transport}
+	scrapers, err := loadScrapers(conf, httpc)
+	if err != nil {
+		return err
+	}
+	transformers, err := urlTransformers(conf)
 	if err != nil {
 		return err
 	}
+	model.SetURLTransformers(transformers)
+
+	events := newBroker()
+	scheduler := job.Start(db, conf, func(r job.Result) {
+		events.publish()
+	}, scrapers...)
+	defer scheduler.Stop()
+
+	fetchCtx, stopFetcher := context.WithCancel(context.Background())
+	defer stopFetcher()
+	go article.NewFetcher(db, article.NewClient(), store).Run(fetchCtx)
 
-	trigger, stopJob := job.Start(db, conf, scrapers...)
-	defer stopJob()
+	go reloadOnSIGHUP(func() error {
+		return reloadConfig(load, store, httpc, scheduler)
+	})
 
 	if conf.ScrapeEnabled() {
-		go trigger(func(r job.Result, err error) error {
+		go scheduler.Trigger(func(r job.Result, err error) error {
 			if err != nil {
 				slog.Error("failed to scrape", "err", err)
 				return nil
@@ -125,48 +207,125 @@ func run(conf config.Config) error {
 		middleware.Recover(),
 		middleware.Logger(),
 		middleware.Secure(),
+		metrics.Middleware(),
 	)
 
 	app.StaticFS("/assets", assets.StaticAssets())
 
 	app.GET("/", func(c echo.Context) error {
-		last := getLastIdFromCookie(c.Cookie("serializer-go"))
-		stories, err := getStories(c.Request().Context(), db, last)
+		s, _, err := sessionFromCookie(c, db, store)
 		if err != nil {
 			return err
 		}
-		unread := unreadCount(stories, last)
-		return views.Index(stories, last, unread).Render(c.Request().Context(), c.Response())
+		return renderIndex(c, db, s, scheduler.Sources())
 	})
 
 	app.GET("/clear", func(c echo.Context) error {
-		writeCookie(c, 0, conf.CookieSecure)
+		writeCookie(c, model.Session{}, store.Get().CookieSecure)
 		return c.Redirect(http.StatusSeeOther, "/")
 	})
 
-	app.GET("/healthz", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]any{"status": "UP"})
+	registerHealth(app, db, scheduler)
+	registerAdmin(app, db, store, scheduler, transport)
+
+	metrics.RegisterStoryCount(func() (int64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return db.Queries().CountStories(ctx)
 	})
+	app.GET("/metrics", metrics.Handler())
 
 	app.POST("/", func(c echo.Context) error {
 		last := getLastIdFromPOST(c)
 		slog.Info("updating last", "last", last)
-		writeCookie(c, last, conf.CookieSecure)
+		s, found, err := sessionFromCookie(c, db, store)
+		if err != nil {
+			return err
+		}
+		s, err = saveSession(c.Request().Context(), db, s, found, last)
+		if err != nil {
+			return err
+		}
+		if err := writeCookie(c, s, store.Get().CookieSecure); err != nil {
+			return err
+		}
 		return c.Redirect(http.StatusSeeOther, "/")
 	})
 
-	app.GET("/scrape", func(c echo.Context) error {
-		return trigger(func(r job.Result, err error) error {
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]any{
-					"err": err,
-				})
-			}
-			return c.JSON(http.StatusOK, r)
+	registerSessions(app, db, store, scheduler)
+	registerSearch(app, db, store, scheduler)
+	registerReader(app, db, store)
+	registerEvents(app, db, store, events)
+
+	app.GET("/stories/:id", func(c echo.Context) error {
+		story, history, err := getStoryWithHistory(c, db)
+		if err != nil {
+			return err
+		}
+		s, _, err := sessionFromCookie(c, db, store)
+		if err != nil {
+			return err
+		}
+		group := []model.StoryGroup{{Story: story}}
+		if err := db.LoadReadingTimes(c.Request().Context(), group); err != nil {
+			return err
+		}
+		var snapshot *model.CommentSnapshot
+		if snap, err := db.Queries().GetCommentSnapshot(c.Request().Context(), story.ID); err == nil {
+			snapshot = &snap
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		score := model.HistorySeries(story, history, model.HistoryFieldScore)
+		comments := model.HistorySeries(story, history, model.HistoryFieldNumComments)
+		return views.StoryDetail(group[0], score, comments, snapshot, s.RewriteLinks).Render(c.Request().Context(), c.Response())
+	})
+
+	app.GET("/stories/:id/history", func(c echo.Context) error {
+		story, history, err := getStoryWithHistory(c, db)
+		if err != nil {
+			return err
+		}
+		changes := make([]HistoryChange, 0, len(history))
+		for _, h := range history {
+			changes = append(changes, HistoryChange{
+				Field:     h.Field,
+				OldVal:    h.OldVal,
+				NewVal:    h.NewVal,
+				CreatedAt: h.CreatedAt,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"story_id": story.ID,
+			"changes":  changes,
 		})
 	})
 
-	return app.Start(":3000")
+	app.GET("/stories/:id/comments", func(c echo.Context) error {
+		story, err := getStory(c, db)
+		if err != nil {
+			return err
+		}
+		snapshot, err := db.Queries().GetCommentSnapshot(c.Request().Context(), story.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "no comments saved for this story yet")
+		}
+		if err != nil {
+			return err
+		}
+		comments, err := snapshot.Comments()
+		if err != nil {
+			return err
+		}
+		return views.CommentThread(story, snapshot, comments).Render(c.Request().Context(), c.Response())
+	})
+
+	app.GET("/feed.atom", feedHandler(db, "application/atom+xml; charset=utf-8", views.AtomFeed))
+	app.GET("/feed.rss", feedHandler(db, "application/rss+xml; charset=utf-8", views.RSSFeed))
+
+	registerAPI(app, db)
+
+	return app.Start(conf.Listen)
 }
 
 func getLastIdFromPOST(c echo.Context) int64 {
@@ -182,42 +341,46 @@ func getLastIdFromPOST(c echo.Context) int64 {
 	return v
 }
 
+const cookieName = "serializer-go"
+
 type CookieVal struct {
-	Last int64 `json:"last"`
+	Last    int64  `json:"last"`
+	Session string `json:"session,omitempty"`
 }
 
-func getLastIdFromCookie(c *http.Cookie, err error) int64 {
+func getCookieVal(c *http.Cookie, err error) CookieVal {
+	v := CookieVal{}
 	if c == nil {
-		return 0
+		return v
 	}
 	if err != nil {
 		slog.Error("could not parse cookie", "err", err, "cookie", c)
-		return 0
+		return v
 	}
-	v := CookieVal{}
 	cv, err := url.QueryUnescape(c.Value)
 	if err != nil {
 		slog.Error("could not url decode cookie", "err", err, "cookie", c)
-		return 0
+		return v
 	}
 	err = json.Unmarshal([]byte(cv), &v)
 	if err != nil {
 		slog.Error("could not decode cookie value", "err", err, "cookie", c)
-		return 0
+		return CookieVal{}
 	}
-	return v.Last
+	return v
 }
 
-func writeCookie(c echo.Context, last int64, secure bool) error {
+func writeCookie(c echo.Context, s model.Session, secure bool) error {
 	b := bytes.Buffer{}
-	err := json.NewEncoder(&b).Encode(CookieVal{Last: last})
+	err := json.NewEncoder(&b).Encode(CookieVal{Last: s.LastRead, Session: s.Token})
 	if err != nil {
 		return err
 	}
 
 	c.SetCookie(&http.Cookie{
-		Name:     "serializer-go",
+		Name:     cookieName,
 		Value:    url.QueryEscape(b.String()),
+		Path:     "/",
 		Expires:  time.Now().Add(time.Hour * 24 * 365),
 		HttpOnly: true,
 		Secure:   secure,
@@ -225,20 +388,157 @@ func writeCookie(c echo.Context, last int64, secure bool) error {
 	return nil
 }
 
-func getStories(ctx context.Context, db *sql.DB, id int64) ([]model.Story, error) {
-	queries := model.New(db)
-	stories, err := queries.ListStoriesBeginningAt(ctx, id-9)
-	return stories, err
+type HistoryChange struct {
+	Field     string    `json:"field"`
+	OldVal    string    `json:"old"`
+	NewVal    string    `json:"new"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// getStory returns the story referenced by the id in the path.
+func getStory(c echo.Context, db *model.DB) (model.Story, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return model.Story{}, echo.NewHTTPError(http.StatusBadRequest, "invalid story id")
+	}
+	story, err := db.Queries().GetStory(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Story{}, echo.NewHTTPError(http.StatusNotFound, "story not found")
+	}
+	return story, err
+}
+
+func getStoryWithHistory(c echo.Context, db *model.DB) (model.Story, []model.StoryHistory, error) {
+	story, err := getStory(c, db)
+	if err != nil {
+		return model.Story{}, nil, err
+	}
+	history, err := db.Queries().ListStoryHistory(c.Request().Context(), story.ID)
+	if err != nil {
+		return model.Story{}, nil, err
+	}
+	return story, history, nil
+}
+
+func getStories(ctx context.Context, db *model.DB, s model.Session) ([]model.StoryGroup, error) {
+	return listStories(ctx, db, s, s.LastRead-9)
 }
 
-func loadScrapers(conf config.Config) ([]scraper.Scraper, error) {
-	httpc := &http.Client{}
-	hnScraper, err := hackernews.NewScraper(httpc)
+// listStories returns the stories beginning at the given id the reader of the
+// session wants to see, newest first.
+func listStories(ctx context.Context, db *model.DB, s model.Session, from int64) ([]model.StoryGroup, error) {
+	queries := db.Queries()
+	stories, err := queries.ListStoriesBeginningAt(ctx, model.ListStoriesBeginningAtParams{
+		ID:             from,
+		HiddenScrapers: s.HiddenScrapers,
+		HiddenTypes:    s.HiddenTypes,
+	})
 	if err != nil {
 		return nil, err
 	}
+	// duplicates of hidden stories are shown in their place
+	hiddenOriginals := map[int64]bool{}
+	for _, id := range model.MissingOriginals(stories) {
+		original, err := queries.GetStory(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !s.Shows(&original) {
+			hiddenOriginals[id] = true
+		}
+	}
+	muter, err := model.LoadMuter(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+	groups := []model.StoryGroup{}
+	for _, g := range model.GroupDuplicates(stories, hiddenOriginals) {
+		if muter.Muted(&g.Story) {
+			if !s.ShowMuted {
+				continue
+			}
+			g.Muted = true
+		}
+		groups = append(groups, g)
+	}
+	if err := db.LoadReadingTimes(ctx, groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func loadScrapers(conf config.Config, httpc *http.Client) ([]scraper.Scraper, error) {
+	scrapers := []scraper.Scraper{}
 
-	return []scraper.Scraper{
-		hnScraper,
-	}, nil
+	for _, name := range conf.Scrapers {
+		switch name {
+		case model.ScraperHN:
+			hnScraper, err := hackernews.NewScraper(httpc, conf.HNBackend, conf.HNPageSize)
+			if err != nil {
+				return nil, err
+			}
+			scrapers = append(scrapers, hnScraper)
+		case model.ScraperLobsters:
+			lobstersScraper, err := lobsters.NewScraper(httpc, lobsters.ListingHottest)
+			if err != nil {
+				return nil, err
+			}
+			scrapers = append(scrapers, lobstersScraper)
+		default:
+			return nil, fmt.Errorf("unknown scraper %q", name)
+		}
+	}
+
+	if len(conf.Subreddits) > 0 {
+		subs := make([]reddit.Subreddit, 0, len(conf.Subreddits))
+		for _, s := range conf.Subreddits {
+			subs = append(subs, reddit.Subreddit{Name: s.Name, MinScore: s.MinScore})
+		}
+		redditScraper, err := reddit.NewScraper(httpc, subs...)
+		if err != nil {
+			return nil, err
+		}
+		scrapers = append(scrapers, redditScraper)
+	}
+
+	for _, f := range conf.Feeds {
+		feedScraper, err := feed.NewScraper(httpc, f.Name, f.URL)
+		if err != nil {
+			return nil, err
+		}
+		if f.Icon != "" {
+			model.SetScraperIcon(feedScraper.Name(), f.Icon)
+		}
+		scrapers = append(scrapers, feedScraper)
+	}
+
+	return scrapers, nil
+}
+
+func urlTransformers(conf config.Config) ([]model.URLTransformer, error) {
+	l := make([]model.URLTransformer, 0, len(conf.URLTransformers))
+	for i, c := range conf.URLTransformers {
+		var t model.URLTransformer
+		var err error
+		switch c.Type {
+		case config.URLTransformerFarside:
+			t = model.NewFarsideTransformer(c.Host, c.Service)
+		case config.URLTransformerFrontend:
+			t, err = model.NewFrontendTransformer(c.Frontend, c.AllHosts()...)
+		case config.URLTransformerRegex:
+			t, err = model.NewRegexTransformer(c.Pattern, c.Replace)
+		case config.URLTransformerArchive:
+			t, err = model.NewArchiveTransformer(c.Service, c.AllHosts()...)
+		default:
+			err = fmt.Errorf("unknown type %q", c.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid url transformer %d: %w", i+1, err)
+		}
+		l = append(l, t)
+	}
+	return l, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFlagOverrides(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{
+			name: "nothing set",
+			want: map[string]string{},
+		},
+		{
+			name: "flags",
+			args: []string{"-listen", ":9090", "-fetch-articles"},
+			want: map[string]string{"listen": ":9090", "fetch-articles": "true"},
+		},
+		{
+			name: "env vars",
+			env:  map[string]string{"LISTEN": ":9090", "FETCH_ARTICLES": "1"},
+			want: map[string]string{"listen": ":9090", "fetch-articles": "1"},
+		},
+		{
+			name: "flags take precedence over env vars",
+			args: []string{"-listen", ":8080", "-fetch-articles=false"},
+			env:  map[string]string{"LISTEN": ":9090", "FETCH_ARTICLES": "true", "SCRAPERS": "hn,lobsters"},
+			want: map[string]string{"listen": ":8080", "fetch-articles": "false", "scrapers": "hn,lobsters"},
+		},
+		{
+			name: "empty env vars are ignored",
+			env:  map[string]string{"LISTEN": ""},
+			want: map[string]string{},
+		},
+		{
+			name: "flags without env var",
+			args: []string{"-cookie-insecure"},
+			env:  map[string]string{"": "ignored"},
+			want: map[string]string{"cookie-insecure": "true"},
+		},
+		{
+			name: "other flags are not overrides",
+			args: []string{"-log-level", "debug"},
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(old map[string]string) { configFlags = old }(configFlags)
+			configFlags = map[string]string{
+				"listen":          "LISTEN",
+				"scrapers":        "SCRAPERS",
+				"cookie-insecure": "",
+				"fetch-articles":  "FETCH_ARTICLES",
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.String("listen", ":3000", "")
+			fs.String("scrapers", "hn", "")
+			fs.Bool("cookie-insecure", false, "")
+			fs.Bool("fetch-articles", false, "")
+			fs.String("log-level", "info", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			got := flagOverrides(fs, func(env string) string { return tt.env[env] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
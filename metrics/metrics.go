@@ -0,0 +1,150 @@
+// Package metrics collects the Prometheus metrics exposed on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "serializer"
+
+var (
+	scrapeStories = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_stories_total",
+		Help:      "Stories processed by the scrapers, by result (new, updated, recent).",
+	}, []string{"scraper", "result"})
+
+	scrapeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_errors_total",
+		Help:      "Errors during scrapes.",
+	}, []string{"scraper"})
+
+	scrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scrape_duration_seconds",
+		Help:      "Duration of a scrape run.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"scraper"})
+
+	fetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Latency of the requests of the scrapers to their sources, code is \"error\" if there was no response.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "code"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of the DB queries.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"query"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the requests handled by the app.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// ObserveScrape records the result of a single run of a scraper.
+func ObserveScrape(scraper string, d time.Duration, created, updated, recent, errors int) {
+	scrapeStories.WithLabelValues(scraper, "new").Add(float64(created))
+	scrapeStories.WithLabelValues(scraper, "updated").Add(float64(updated))
+	scrapeStories.WithLabelValues(scraper, "recent").Add(float64(recent))
+	scrapeErrors.WithLabelValues(scraper).Add(float64(errors))
+	scrapeDuration.WithLabelValues(scraper).Observe(d.Seconds())
+}
+
+// ObserveDBQuery records the latency of a DB query.
+func ObserveDBQuery(query string, d time.Duration) {
+	dbQueryDuration.WithLabelValues(query).Observe(d.Seconds())
+}
+
+// RegisterStoryCount exposes the number of stored stories, count is called on
+// every collection.
+func RegisterStoryCount(count func() (int64, error)) {
+	prometheus.MustRegister(&storyCountCollector{count: count})
+}
+
+var storyCountDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "stories"), "Number of stored stories.", nil, nil)
+
+type storyCountCollector struct {
+	count func() (int64, error)
+}
+
+func (c *storyCountCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storyCountDesc
+}
+
+func (c *storyCountCollector) Collect(ch chan<- prometheus.Metric) {
+	n, err := c.count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(storyCountDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(storyCountDesc, prometheus.GaugeValue, float64(n))
+}
+
+// roundTripper records latency and status of the requests to the sources.
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+// InstrumentRoundTripper wraps base, http.DefaultTransport is used if base is
+// nil.
+func InstrumentRoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{base: base}
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	fetchDuration.WithLabelValues(req.URL.Host, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// Middleware records latency and status of the requests handled by the app.
+// Requests are labeled with their route, so ids in paths don't create new
+// series.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			code := c.Response().Status
+			if he, ok := err.(*echo.HTTPError); ok && !c.Response().Committed {
+				code = he.Code
+			} else if err != nil && !c.Response().Committed {
+				code = http.StatusInternalServerError
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			httpRequestDuration.WithLabelValues(c.Request().Method, route, strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// Handler serves the metrics.
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.Handler())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/model"
+)
+
+// runMigrate implements the migrate command:
+//
+//	serializer-go [flags] migrate [status|up]
+func runMigrate(conf config.Config, args []string) error {
+	if conf.DBURI == "" {
+		return fmt.Errorf("db-uri is mandatory but was not set")
+	}
+
+	sub := "status"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	db, err := model.OpenDB(conf)
+	if err != nil {
+		return fmt.Errorf("could not initialize DB connection: %w", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch sub {
+	case "status":
+		return printMigrationStatus(ctx, db)
+	case "up":
+		applied, err := model.Migrate(ctx, db)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, expected status or up", sub)
+}
+
+func printMigrationStatus(ctx context.Context, db *model.DB) error {
+	status, err := model.GetMigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if !s.Pending() {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	ArticleOK = "ok"
+	// ArticleFailed articles can't be fetched, e.g. because the page is gone,
+	// or had no article text, they are not fetched again
+	ArticleFailed = "failed"
+	// ArticleRetry articles failed for a reason that may go away, like a
+	// timeout or a server error, and are fetched again after a while
+	ArticleRetry = "retry"
+)
+
+// words read per minute to estimate the reading time
+const readingWordsPerMinute = 230
+
+// ReadingMinutes estimates how long it takes to read the given number of
+// words, at least one minute for any text.
+func ReadingMinutes(words int) int32 {
+	if words <= 0 {
+		return 0
+	}
+	return int32((words + readingWordsPerMinute - 1) / readingWordsPerMinute)
+}
+
+// Paragraphs splits the content of the article into its paragraphs.
+func (a *Article) Paragraphs() []string {
+	if a.Content == "" {
+		return nil
+	}
+	return strings.Split(a.Content, "\n\n")
+}
+
+// LoadReadingTimes sets the reading time of the stories whose article has
+// been fetched. The articles are looked up by the range of ids, pages are
+// mostly consecutive stories.
+func (db *DB) LoadReadingTimes(ctx context.Context, groups []StoryGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	params := ListReadingTimesParams{MinID: groups[0].ID, MaxID: groups[0].ID}
+	index := map[int64]int{}
+	for i, g := range groups {
+		index[g.ID] = i
+		params.MinID = min(params.MinID, g.ID)
+		params.MaxID = max(params.MaxID, g.ID)
+	}
+
+	times, err := db.Queries().ListReadingTimes(ctx, params)
+	if err != nil {
+		return err
+	}
+	for _, t := range times {
+		if i, ok := index[t.StoryID]; ok {
+			groups[i].ReadingMinutes = t.ReadingMinutes
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadReadingTimes(t *testing.T) {
+	for dialect, open := range testBackends(t) {
+		t.Run(string(dialect), func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t)
+			q := db.Queries()
+
+			stories := []Story{}
+			for i, ref := range []string{"1", "2", "3", "4", "5"} {
+				s := mustCreate(t, q, newStory(ScraperHN, ref, "https://example.com/"+ref))
+				stories = append(stories, s)
+				status := ArticleOK
+				if i == 3 {
+					status = ArticleFailed
+				}
+				if _, err := q.SaveArticle(ctx, SaveArticleParams{StoryID: s.ID, Status: status, ReadingMinutes: int32(i + 1), FetchedAt: time.Now()}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// story 3 is within the range of the page but not part of it
+			groups := []StoryGroup{{Story: stories[4]}, {Story: stories[3]}, {Story: stories[1]}, {Story: stories[0]}}
+			if err := db.LoadReadingTimes(ctx, groups); err != nil {
+				t.Fatal(err)
+			}
+			got := []int32{}
+			for _, g := range groups {
+				got = append(got, g.ReadingMinutes)
+			}
+			if want := []int32{5, 0, 2, 1}; !slices.Equal(got, want) {
+				t.Errorf("got reading times %v, want %v", got, want)
+			}
+			if err := db.LoadReadingTimes(ctx, nil); err != nil {
+				t.Errorf("got error %v without stories", err)
+			}
+		})
+	}
+}
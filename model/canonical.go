@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// canonicalizers are all applied in order, unlike urlTransformers where only
+// the first match is used. ref is not a tracking param everywhere, e.g. it
+// selects the branch of GitHub and GitLab links, so it is kept.
+var canonicalizers = []URLTransformer{
+	&hostCanonicalizer{},
+	&trackingParamsCanonicalizer{
+		prefixes: []string{"utm_", "pk_", "mtm_"},
+		params: []string{
+			"fbclid", "gclid", "dclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid",
+			"_hsenc", "_hsmi", "mkt_tok", "ref_src", "ref_url", "s_cid", "cmpid", "sr_share",
+		},
+	},
+	&pathCanonicalizer{},
+}
+
+// CanonicalURL normalizes u so the same article submitted to different
+// sources results in the same string. It returns an empty string for empty
+// or unparseable URLs.
+func CanonicalURL(u string) string {
+	u = strings.TrimSpace(u)
+	if u == "" {
+		return ""
+	}
+	pu, err := url.Parse(u)
+	if err != nil || pu.Host == "" {
+		return ""
+	}
+	for _, c := range canonicalizers {
+		if c.Matches(pu) {
+			pu = c.Transform(pu)
+		}
+	}
+	return pu.String()
+}
+
+// hostCanonicalizer lowercases the host and drops www. and default ports.
+// The scheme is unified to https, most sites serve both.
+type hostCanonicalizer struct{}
+
+func (c *hostCanonicalizer) Matches(u *url.URL) bool {
+	return true
+}
+
+func (c *hostCanonicalizer) Transform(u *url.URL) *url.URL {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch port := u.Port(); port {
+	case "", "80", "443":
+		u.Host = host
+	default:
+		u.Host = host + ":" + port
+	}
+	u.Scheme = "https"
+	u.User = nil
+	return u
+}
+
+type trackingParamsCanonicalizer struct {
+	prefixes []string
+	params   []string
+}
+
+func (c *trackingParamsCanonicalizer) Matches(u *url.URL) bool {
+	return u.RawQuery != ""
+}
+
+func (c *trackingParamsCanonicalizer) isTracking(param string) bool {
+	param = strings.ToLower(param)
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(param, p) {
+			return true
+		}
+	}
+	for _, p := range c.params {
+		if param == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Transform removes tracking parameters, the remaining ones are sorted by
+// the encoding.
+func (c *trackingParamsCanonicalizer) Transform(u *url.URL) *url.URL {
+	q := u.Query()
+	for k := range q {
+		if c.isTracking(k) {
+			q.Del(k)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// pathCanonicalizer drops fragments and trailing slashes.
+type pathCanonicalizer struct{}
+
+func (c *pathCanonicalizer) Matches(u *url.URL) bool {
+	return true
+}
+
+func (c *pathCanonicalizer) Transform(u *url.URL) *url.URL {
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u
+}
+
+// stories are backfilled in batches of this size
+const backfillBatchSize = 1000
+
+// backfillCanonicalURLs sets the canonical url of all stories with the
+// current rules, stories created before the column existed have none. The
+// story_changes trigger ignores canonical_url, so updated_at is kept.
+func backfillCanonicalURLs(ctx context.Context, tx DBTX) error {
+	type update struct {
+		id           int64
+		canonicalURL string
+	}
+	lastID := int64(0)
+	for {
+		rows, err := tx.QueryContext(ctx, "select id, url, canonical_url from stories where id > $1 order by id limit $2", lastID, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+		n := 0
+		updates := []update{}
+		for rows.Next() {
+			var u, canonicalURL string
+			if err := rows.Scan(&lastID, &u, &canonicalURL); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+			if c := CanonicalURL(u); c != canonicalURL {
+				updates = append(updates, update{id: lastID, canonicalURL: c})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, u := range updates {
+			if _, err := tx.ExecContext(ctx, "update stories set canonical_url = $1 where id = $2", u.canonicalURL, u.id); err != nil {
+				return err
+			}
+		}
+		if n < backfillBatchSize {
+			return nil
+		}
+	}
+}
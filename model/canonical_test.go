@@ -0,0 +1,72 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackfillCanonicalURLs(t *testing.T) {
+	for dialect, open := range testBackends(t) {
+		t.Run(string(dialect), func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t)
+			q := db.Queries()
+			dbtx := db.DBTX(db.DB)
+
+			stale := newStory(ScraperHN, "1", "https://github.com/floj/serializer-go/tree/x?ref=main&utm_source=hn")
+			stale.CanonicalUrl = "https://github.com/floj/serializer-go/tree/x"
+			s := mustCreate(t, q, stale)
+			missing := newStory(ScraperHN, "2", "https://www.example.com/a/")
+			missing.CanonicalUrl = ""
+			m := mustCreate(t, q, missing)
+
+			// stories last updated by their scraper a while ago
+			longAgo := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+			if _, err := dbtx.ExecContext(ctx, "update stories set updated_at = $1", longAgo); err != nil {
+				t.Fatal(err)
+			}
+			if err := backfillCanonicalURLs(ctx, dbtx); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tt := range []struct {
+				id   int64
+				want string
+			}{
+				{s.ID, "https://github.com/floj/serializer-go/tree/x?ref=main"},
+				{m.ID, "https://example.com/a"},
+			} {
+				got, err := q.GetStory(ctx, tt.id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.CanonicalUrl != tt.want {
+					t.Errorf("story %s: got canonical url %q, want %q", got.RefID, got.CanonicalUrl, tt.want)
+				}
+				if !got.UpdatedAt.Equal(longAgo) {
+					t.Errorf("story %s: updated at %s, want it kept at %s", got.RefID, got.UpdatedAt, longAgo)
+				}
+				history, err := q.ListStoryHistory(ctx, tt.id)
+				if err != nil || len(history) != 0 {
+					t.Errorf("story %s: got history %+v, %v, want none", got.RefID, history, err)
+				}
+			}
+
+			// updates by the scrapers still touch updated_at
+			if _, err := q.UpdateStory(ctx, UpdateStoryParams{
+				ID: s.ID, Title: s.Title, Url: s.Url, CanonicalUrl: s.CanonicalUrl, Score: s.Score, NumComments: s.NumComments, Type: s.Type, LastSeenFp: s.LastSeenFp,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			// SQLite sets updated_at after the update, so it is read again
+			updated, err := q.GetStory(ctx, s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !updated.UpdatedAt.After(longAgo) {
+				t.Errorf("updated at %s after an update by the scraper, want it touched", updated.UpdatedAt)
+			}
+		})
+	}
+}
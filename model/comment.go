@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Comment is a comment of a discussion together with its replies, as stored
+// in the comment snapshots.
+type Comment struct {
+	ID int64 `json:"id"`
+	// By and Text are empty for deleted comments
+	By string `json:"by"`
+	// Text is HTML as served by the source
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	Children  []Comment `json:"children,omitempty"`
+}
+
+// Deleted reports whether the comment was deleted, its replies are kept.
+func (c *Comment) Deleted() bool {
+	return c.By == "" && c.Text == ""
+}
+
+// CountComments returns the number of comments including all replies.
+func CountComments(comments []Comment) int {
+	n := 0
+	for _, c := range comments {
+		n += 1 + CountComments(c.Children)
+	}
+	return n
+}
+
+// NewCommentSnapshot returns the parameters to store the comment tree of the
+// story.
+func NewCommentSnapshot(storyID int64, comments []Comment) (SaveCommentSnapshotParams, error) {
+	tree, err := json.Marshal(comments)
+	if err != nil {
+		return SaveCommentSnapshotParams{}, err
+	}
+	return SaveCommentSnapshotParams{
+		StoryID:     storyID,
+		TakenAt:     time.Now(),
+		NumComments: int32(CountComments(comments)),
+		Tree:        string(tree),
+	}, nil
+}
+
+// Comments decodes the stored comment tree.
+func (s *CommentSnapshot) Comments() ([]Comment, error) {
+	comments := []Comment{}
+	if err := json.Unmarshal([]byte(s.Tree), &comments); err != nil {
+		return nil, fmt.Errorf("invalid comment snapshot of story %d: %w", s.StoryID, err)
+	}
+	return comments, nil
+}
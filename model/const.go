@@ -8,3 +8,22 @@ const (
 	TypeHNJob    = "job"
 	TypeUnknown  = "unknown"
 )
+
+const (
+	ScraperLobsters   = "lobsters"
+	TypeLobstersStory = "story"
+	TypeLobstersAsk   = "ask"
+	TypeLobstersShow  = "show"
+)
+
+const (
+	// ScraperFeedPrefix is prepended to the configured name of each feed
+	ScraperFeedPrefix = "feed:"
+	TypeFeedEntry     = "entry"
+)
+
+const (
+	ScraperReddit  = "reddit"
+	TypeRedditLink = "link"
+	TypeRedditSelf = "self"
+)
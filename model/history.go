@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	HistoryFieldScore       = "score"
+	HistoryFieldNumComments = "num_comments"
+)
+
+type HistoryPoint struct {
+	At    time.Time
+	Value int64
+}
+
+// HistorySeries reconstructs how a numeric field of the story changed over
+// time. It starts with the value the story was created with and ends with
+// the current value at the time the story was last updated.
+func HistorySeries(s Story, history []StoryHistory, field string) []HistoryPoint {
+	current := int64(0)
+	switch field {
+	case HistoryFieldScore:
+		current = int64(s.Score)
+	case HistoryFieldNumComments:
+		current = int64(s.NumComments)
+	}
+
+	changes := []StoryHistory{}
+	for _, h := range history {
+		if h.Field == field {
+			changes = append(changes, h)
+		}
+	}
+
+	initial := current
+	if len(changes) > 0 {
+		if v, err := strconv.ParseInt(changes[0].OldVal, 10, 64); err == nil {
+			initial = v
+		}
+	}
+
+	points := []HistoryPoint{{At: s.CreatedAt, Value: initial}}
+	for _, c := range changes {
+		v, err := strconv.ParseInt(c.NewVal, 10, 64)
+		if err != nil {
+			continue
+		}
+		points = append(points, HistoryPoint{At: c.CreatedAt, Value: v})
+	}
+	last := points[len(points)-1].At
+	if s.UpdatedAt.After(last) {
+		points = append(points, HistoryPoint{At: s.UpdatedAt, Value: current})
+	}
+	return points
+}
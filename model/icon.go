@@ -0,0 +1,40 @@
+package model
+
+import (
+	"strings"
+	"sync"
+)
+
+var scraperIcons = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// SetScraperIcon overrides the icon shown for stories of the given scraper.
+func SetScraperIcon(scraper, icon string) {
+	scraperIcons.Lock()
+	defer scraperIcons.Unlock()
+	scraperIcons.m[scraper] = icon
+}
+
+func IsFeedScraper(scraper string) bool {
+	return strings.HasPrefix(scraper, ScraperFeedPrefix)
+}
+
+func (s *Story) IconURL() string {
+	return ScraperIconURL(s.Scraper)
+}
+
+// ScraperIconURL returns the icon shown for stories of the given scraper.
+func ScraperIconURL(scraper string) string {
+	scraperIcons.RLock()
+	icon, ok := scraperIcons.m[scraper]
+	scraperIcons.RUnlock()
+	if ok && icon != "" {
+		return icon
+	}
+	if IsFeedScraper(scraper) {
+		return "/assets/images/feed.svg"
+	}
+	return "/assets/images/" + scraper + ".svg"
+}
@@ -3,24 +3,93 @@ package model
 import (
 	"context"
 	"database/sql"
-	_ "embed"
+	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/floj/serializer-go/config"
 	_ "github.com/lib/pq"
 )
 
-//go:embed schema.sql
-var ddl string
+type Dialect string
 
-func InitDB(conf config.Config) (*sql.DB, error) {
-	ctx := context.Background()
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// DB is a connection to one of the supported databases.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Queries returns the sqlc queries for db, adapted to its dialect.
+func (db *DB) Queries() *Queries {
+	return New(timedDBTX{db: db.DBTX(db.DB)})
+}
+
+// DBTX adapts a connection or transaction of db to its dialect.
+func (db *DB) DBTX(d DBTX) DBTX {
+	if db.Dialect == DialectSQLite {
+		return sqliteDBTX{db: d}
+	}
+	return d
+}
+
+// parseDBURI picks the driver by the scheme of uri, sqlite://path/to/file.db
+// selects SQLite, everything else is handed to the Postgres driver.
+func parseDBURI(uri string) (Dialect, string, string, error) {
+	scheme, rest, _ := strings.Cut(uri, ":")
+	switch strings.ToLower(scheme) {
+	case "sqlite", "sqlite3":
+		if sqliteDriver == "" {
+			return "", "", "", fmt.Errorf("SQLite support requires a build with cgo enabled")
+		}
+		file, query, _ := strings.Cut(strings.TrimPrefix(rest, "//"), "?")
+		if file == "" {
+			return "", "", "", fmt.Errorf("db uri %q does not contain a file name", uri)
+		}
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid parameters in db uri: %w", err)
+		}
+		for k, v := range sqliteDefaultParams {
+			if !params.Has(k) {
+				params.Set(k, v)
+			}
+		}
+		return DialectSQLite, sqliteDriver, "file:" + file + "?" + params.Encode(), nil
+	}
+	return DialectPostgres, "postgres", uri, nil
+}
+
+// OpenDB connects to the DB without touching the schema.
+func OpenDB(conf config.Config) (*DB, error) {
+	dialect, driver, dsn, err := parseDBURI(conf.DBURI)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{DB: db, Dialect: dialect}, nil
+}
 
-	db, err := sql.Open("postgres", conf.DBURI)
+// InitDB connects to the DB and applies all pending migrations.
+func InitDB(conf config.Config) (*DB, error) {
+	db, err := OpenDB(conf)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := db.ExecContext(ctx, ddl); err != nil {
+	if _, err := Migrate(context.Background(), db); err != nil {
 		db.Close()
 		return nil, err
 	}
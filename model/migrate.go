@@ -0,0 +1,222 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// arbitrary key for pg_advisory_lock, so only one instance migrates at a time
+const migrationLockID = 0x73657269616c
+
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+func (m MigrationStatus) Pending() bool {
+	return m.AppliedAt == nil
+}
+
+// Migrations returns the embedded migrations of the dialect ordered by
+// version. Files are named <version>_<name>.sql.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/"+string(dialect)+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	seen := map[int64]string{}
+	for _, f := range files {
+		base := strings.TrimSuffix(path.Base(f), ".sql")
+		v, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q, expected <version>_<name>.sql", f)
+		}
+		version, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in migration file name %q: %w", f, err)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %q and %q have the same version", other, f)
+		}
+		seen[version] = f
+
+		b, err := migrationFiles.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(b)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrationHooks run after the SQL of the migration of the same name, in its
+// transaction, for changes that need Go code.
+var migrationHooks = map[string]func(ctx context.Context, tx DBTX) error{
+	"backfill_canonical_url": backfillCanonicalURLs,
+}
+
+var createMigrationsTable = map[Dialect]string{
+	DialectPostgres: `create table if not exists schema_migrations (
+  version bigint not null primary key,
+  name text not null,
+  applied_at timestamp with time zone not null default current_timestamp
+)`,
+	DialectSQLite: `create table if not exists schema_migrations (
+  version integer not null primary key,
+  name text not null,
+  applied_at timestamp not null default current_timestamp
+)`,
+}
+
+func appliedMigrations(ctx context.Context, db *DB, conn DBTX) (map[int64]time.Time, error) {
+	if _, err := conn.ExecContext(ctx, createMigrationsTable[db.Dialect]); err != nil {
+		return nil, fmt.Errorf("could not create migrations table: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// GetMigrationStatus lists all known migrations and whether they have been
+// applied to db.
+func GetMigrationStatus(ctx context.Context, db *DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db, db.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			s.AppliedAt = &t
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction. On
+// Postgres an advisory lock keeps concurrently starting instances from
+// migrating at the same time, SQLite transactions take the write lock
+// immediately instead.
+func Migrate(ctx context.Context, db *DB) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if db.Dialect == DialectPostgres {
+		if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+			return nil, fmt.Errorf("could not acquire migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", migrationLockID); err != nil {
+				slog.Error("could not release migration lock", "err", err)
+			}
+		}()
+	}
+
+	// read the applied migrations only after acquiring the lock, another
+	// instance might just have finished migrating
+	applied, err := appliedMigrations(ctx, db, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		slog.Info("applying migration", "version", m.Version, "name", m.Name)
+		ok, err := applyMigration(ctx, db, conn, m)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// applyMigration reports false if m was applied concurrently by another
+// instance in the meantime.
+func applyMigration(ctx context.Context, db *DB, conn *sql.Conn, m Migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	dbtx := db.DBTX(tx)
+	var applied int
+	if err := dbtx.QueryRowContext(ctx, "select count(*) from schema_migrations where version = $1", m.Version).Scan(&applied); err != nil {
+		return false, err
+	}
+	if applied > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, err
+	}
+	if hook, ok := migrationHooks[m.Name]; ok {
+		if err := hook(ctx, dbtx); err != nil {
+			return false, err
+		}
+	}
+	if _, err := dbtx.ExecContext(ctx, "insert into schema_migrations (version, name) values ($1, $2)", m.Version, m.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,5 @@
+alter table stories add column canonical_url text not null default '';
+-- set for stories linking to the same article as an earlier story from another source
+alter table stories add column duplicate_of bigint references stories(id) on delete set null;
+
+create index if not exists stories_canonical_url_idx on stories(canonical_url);
@@ -0,0 +1,6 @@
+create table if not exists sessions (
+  token text not null primary key,
+  last_read bigint not null default 0,
+  created_at timestamp with time zone not null default current_timestamp,
+  updated_at timestamp with time zone not null default current_timestamp
+);
@@ -0,0 +1,3 @@
+-- comma separated scrapers and scraper:type pairs the reader does not want to see
+alter table sessions add column hidden_scrapers text not null default '';
+alter table sessions add column hidden_types text not null default '';
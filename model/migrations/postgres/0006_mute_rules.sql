@@ -0,0 +1,9 @@
+create table if not exists mute_rules (
+  id bigserial not null primary key,
+  kind text not null,
+  pattern text not null,
+  created_at timestamp with time zone not null default current_timestamp,
+  unique (kind, pattern)
+);
+
+alter table sessions add column show_muted boolean not null default false;
@@ -0,0 +1,16 @@
+-- words of title, domain and author for full-text search, immutable so the
+-- expression can be indexed. Domains are indexed as a whole and split at dots.
+create or replace function story_search_vector(title text, author text, url text)
+  returns tsvector
+  language sql
+  immutable
+  as
+$$
+  select
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', coalesce(d.domain || ' ' || replace(d.domain, '.', ' '), '')), 'B') ||
+    setweight(to_tsvector('english', author), 'C')
+  from (select substring(url from '^[a-zA-Z]+://(?:www\.)?([^/:?#]+)') as domain) d
+$$;
+
+create index if not exists stories_search_idx on stories using gin (story_search_vector(title, by, url));
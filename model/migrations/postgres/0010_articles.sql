@@ -0,0 +1,17 @@
+-- text of the article a story links to, extracted by the article fetcher
+create table if not exists articles (
+  story_id bigint not null primary key references stories(id) on delete cascade,
+  -- ok, failed or retry, both failures keep the reason in error. failed articles
+  -- are not fetched again, retry ones are fetched again after a while until
+  -- attempts, added in 0012, reaches the limit
+  status text not null,
+  title text not null default '',
+  -- paragraphs separated by blank lines
+  content text not null default '',
+  word_count integer not null default 0,
+  reading_minutes integer not null default 0,
+  error text not null default '',
+  fetched_at timestamp with time zone not null
+);
+
+create index if not exists articles_search_idx on articles using gin (to_tsvector('english', content));
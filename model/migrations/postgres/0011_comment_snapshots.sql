@@ -0,0 +1,8 @@
+-- comment tree of a story, taken when it leaves the front page
+create table if not exists comment_snapshots (
+  story_id bigint not null primary key references stories(id) on delete cascade,
+  taken_at timestamp with time zone not null,
+  num_comments integer not null default 0,
+  -- the tree as JSON, see model.Comment
+  tree text not null
+);
@@ -0,0 +1,3 @@
+-- articles that failed for a reason that may go away, like a timeout, are
+-- stored as retry and fetched again until attempts reaches the limit
+alter table articles add column attempts integer not null default 1;
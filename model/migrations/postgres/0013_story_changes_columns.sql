@@ -0,0 +1,7 @@
+-- only updates of the columns set by the scrapers touch updated_at and the
+-- history, so maintenance like recomputing canonical_url keeps the time a
+-- story was last updated by its source
+drop trigger if exists story_changes ON stories;
+
+create trigger story_changes before update of url, title, score, num_comments, deleted, type, last_seen_fp on stories
+  for each row execute procedure record_story_changes();
@@ -0,0 +1,4 @@
+-- canonical_url is computed in Go by backfillCanonicalURLs, which runs after
+-- this migration. It fills in the stories created before the column existed
+-- and updates the ones whose canonical url changed with the rules, e.g. when
+-- ref stopped being stripped.
@@ -0,0 +1,60 @@
+-- timestamps are stored as UTC text in the format written by strftime below,
+-- so they compare correctly as strings
+create table if not exists stories (
+  id integer not null primary key autoincrement,
+  ref_id text not null,
+  url text not null,
+  by text not null,
+  published_at timestamp not null,
+  updated_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  created_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  last_seen_fp timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  title text not null,
+  type text not null,
+  score integer not null,
+  num_comments integer not null,
+  scraper text not null,
+  deleted boolean not null default false
+);
+
+create index if not exists stories_created_at_idx on stories(created_at);
+create unique index if not exists stories_ref_id_idx on stories(ref_id);
+
+create table if not exists story_history (
+  id integer not null primary key autoincrement,
+  story_id bigint not null references stories(id) on delete cascade,
+  field text not null,
+  old_val text not null,
+  new_val text not null,
+  created_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- SQLite can't modify NEW in a trigger, so updated_at is set after the update.
+-- Recursive triggers are off by default, the nested update doesn't fire it again.
+drop trigger if exists story_changes;
+
+create trigger story_changes after update on stories for each row
+begin
+  update stories set updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') where id = NEW.id;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'score', OLD.score, NEW.score where NEW.score != OLD.score;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'num_comments', OLD.num_comments, NEW.num_comments where NEW.num_comments != OLD.num_comments;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'url', OLD.url, NEW.url where NEW.url != OLD.url;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'title', OLD.title, NEW.title where NEW.title != OLD.title;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'deleted',
+    case when OLD.deleted then 'true' else 'false' end,
+    case when NEW.deleted then 'true' else 'false' end
+  where NEW.deleted != OLD.deleted;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'type', OLD.type, NEW.type where NEW.type != OLD.type;
+end;
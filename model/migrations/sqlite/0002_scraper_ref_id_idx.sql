@@ -0,0 +1,3 @@
+-- ref ids are only unique per scraper, e.g. feed GUIDs may show up in several feeds
+drop index if exists stories_ref_id_idx;
+create unique index if not exists stories_scraper_ref_id_idx on stories(scraper, ref_id);
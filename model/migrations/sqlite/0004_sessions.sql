@@ -0,0 +1,6 @@
+create table if not exists sessions (
+  token text not null primary key,
+  last_read integer not null default 0,
+  created_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  updated_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
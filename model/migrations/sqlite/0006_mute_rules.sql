@@ -0,0 +1,9 @@
+create table if not exists mute_rules (
+  id integer not null primary key autoincrement,
+  kind text not null,
+  pattern text not null,
+  created_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  unique (kind, pattern)
+);
+
+alter table sessions add column show_muted boolean not null default false;
@@ -0,0 +1,16 @@
+create table if not exists scrape_runs (
+  id integer not null primary key autoincrement,
+  scraper text not null,
+  trigger text not null,
+  started_at timestamp not null,
+  finished_at timestamp not null,
+  new_count integer not null default 0,
+  updated_count integer not null default 0,
+  recent_count integer not null default 0,
+  error_count integer not null default 0,
+  -- one message per line
+  errors text not null default ''
+);
+
+create index if not exists scrape_runs_started_at_idx on scrape_runs(started_at);
+create index if not exists scrape_runs_scraper_idx on scrape_runs(scraper, started_at);
@@ -0,0 +1,2 @@
+-- whether the links of stories are rewritten by the configured url transformers
+alter table sessions add column rewrite_links boolean not null default true;
@@ -0,0 +1,30 @@
+-- only updates of the columns set by the scrapers touch updated_at and the
+-- history, so maintenance like recomputing canonical_url keeps the time a
+-- story was last updated by its source
+drop trigger if exists story_changes;
+
+create trigger story_changes after update of url, title, score, num_comments, deleted, type, last_seen_fp on stories for each row
+begin
+  update stories set updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') where id = NEW.id;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'score', OLD.score, NEW.score where NEW.score != OLD.score;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'num_comments', OLD.num_comments, NEW.num_comments where NEW.num_comments != OLD.num_comments;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'url', OLD.url, NEW.url where NEW.url != OLD.url;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'title', OLD.title, NEW.title where NEW.title != OLD.title;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'deleted',
+    case when OLD.deleted then 'true' else 'false' end,
+    case when NEW.deleted then 'true' else 'false' end
+  where NEW.deleted != OLD.deleted;
+
+  insert into story_history(story_id, field, old_val, new_val)
+  select OLD.id, 'type', OLD.type, NEW.type where NEW.type != OLD.type;
+end;
@@ -5,24 +5,77 @@
 package model
 
 import (
+	"database/sql"
 	"time"
 )
 
-type Story struct {
-	ID          int64
-	RefID       string
-	Url         string
-	By          string
-	PublishedAt time.Time
-	UpdatedAt   time.Time
-	CreatedAt   time.Time
-	LastSeenFp  time.Time
-	Title       string
-	Type        string
-	Score       int32
+type Article struct {
+	StoryID        int64
+	Status         string
+	Title          string
+	Content        string
+	WordCount      int32
+	ReadingMinutes int32
+	Error          string
+	FetchedAt      time.Time
+	Attempts       int32
+}
+
+type CommentSnapshot struct {
+	StoryID     int64
+	TakenAt     time.Time
 	NumComments int32
-	Scraper     string
-	Deleted     bool
+	Tree        string
+}
+
+type MuteRule struct {
+	ID        int64
+	Kind      string
+	Pattern   string
+	CreatedAt time.Time
+}
+
+type ScrapeRun struct {
+	ID           int64
+	Scraper      string
+	Trigger      string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	NewCount     int32
+	UpdatedCount int32
+	RecentCount  int32
+	ErrorCount   int32
+	Errors       string
+}
+
+type Session struct {
+	Token          string
+	LastRead       int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HiddenScrapers string
+	HiddenTypes    string
+	ShowMuted      bool
+	RewriteLinks   bool
+}
+
+type Story struct {
+	ID           int64
+	RefID        string
+	Url          string
+	By           string
+	PublishedAt  time.Time
+	UpdatedAt    time.Time
+	CreatedAt    time.Time
+	LastSeenFp   time.Time
+	Title        string
+	Type         string
+	Score        int32
+	NumComments  int32
+	Scraper      string
+	Deleted      bool
+	CanonicalUrl string
+	DuplicateOf  sql.NullInt64
 }
 
 type StoryHistory struct {
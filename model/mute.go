@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MuteKindTitle rules are case insensitive regular expressions matched
+	// against the title
+	MuteKindTitle = "title"
+	// MuteKindDomain rules match the domain, its subdomains and, for hosts
+	// grouped by Story.Domain like github.com, the repos below it
+	MuteKindDomain = "domain"
+)
+
+// NormalizeMuteRule validates the pattern of a rule of the given kind and
+// returns it in the form it is stored.
+func NormalizeMuteRule(kind, pattern string) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return "", fmt.Errorf("pattern must not be empty")
+	}
+	switch kind {
+	case MuteKindTitle:
+		if _, err := compileTitleRule(pattern); err != nil {
+			return "", fmt.Errorf("invalid title pattern: %w", err)
+		}
+		return pattern, nil
+	case MuteKindDomain:
+		d := strings.ToLower(pattern)
+		if strings.Contains(d, "://") {
+			u, err := url.Parse(d)
+			if err != nil {
+				return "", fmt.Errorf("invalid domain: %w", err)
+			}
+			d = u.Host + u.Path
+		}
+		d = strings.TrimPrefix(d, "www.")
+		d = strings.TrimSuffix(d, "/")
+		if d == "" || strings.ContainsAny(d, " ?#") {
+			return "", fmt.Errorf("invalid domain %q", pattern)
+		}
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown kind %q, expected %q or %q", kind, MuteKindTitle, MuteKindDomain)
+}
+
+func compileTitleRule(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+type titleRule struct {
+	rule MuteRule
+	re   *regexp.Regexp
+}
+
+// Muter matches stories against the mute rules.
+type Muter struct {
+	titles  []titleRule
+	domains []MuteRule
+}
+
+// NewMuter compiles the given rules, rules that do not compile are skipped.
+func NewMuter(rules []MuteRule) *Muter {
+	m := &Muter{}
+	for _, r := range rules {
+		switch r.Kind {
+		case MuteKindTitle:
+			re, err := compileTitleRule(r.Pattern)
+			if err != nil {
+				slog.Error("skipping invalid mute rule", "id", r.ID, "pattern", r.Pattern, "err", err)
+				continue
+			}
+			m.titles = append(m.titles, titleRule{rule: r, re: re})
+		case MuteKindDomain:
+			m.domains = append(m.domains, r)
+		default:
+			slog.Error("skipping mute rule of unknown kind", "id", r.ID, "kind", r.Kind)
+		}
+	}
+	return m
+}
+
+// LoadMuter reads the mute rules from the DB.
+func LoadMuter(ctx context.Context, q *Queries) (*Muter, error) {
+	rules, err := q.ListMuteRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not load mute rules: %w", err)
+	}
+	return NewMuter(rules), nil
+}
+
+// Match returns the first rule muting the story.
+func (m *Muter) Match(s *Story) (MuteRule, bool) {
+	for _, t := range m.titles {
+		if t.re.MatchString(s.Title) {
+			return t.rule, true
+		}
+	}
+	domain := strings.ToLower(s.Domain())
+	if domain == "" {
+		return MuteRule{}, false
+	}
+	host, path, hasPath := strings.Cut(domain, "/")
+	host = strings.TrimPrefix(host, "www.")
+	domain = host
+	if hasPath {
+		domain = host + "/" + path
+	}
+	for _, r := range m.domains {
+		if domain == r.Pattern || strings.HasPrefix(domain, r.Pattern+"/") || strings.HasSuffix(host, "."+r.Pattern) {
+			return r, true
+		}
+	}
+	return MuteRule{}, false
+}
+
+// Muted reports whether any rule mutes the story.
+func (m *Muter) Muted(s *Story) bool {
+	_, ok := m.Match(s)
+	return ok
+}
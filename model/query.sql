@@ -1,15 +1,24 @@
+-- hidden_scrapers and hidden_types are comma separated lists. A value is in a
+-- list if removing ",value," from ",list," changes it. Unlike LIKE this is an
+-- exact match, names like feed:my_blog contain wildcards of LIKE.
+
 -- name: ListStoriesBeginningAt :many
-SELECT * FROM stories WHERE id >= $1 ORDER BY id desc LIMIT 1000;
+SELECT * FROM stories
+WHERE id >= sqlc.arg(id)
+  AND replace(',' || CAST(sqlc.arg(hidden_scrapers) AS text) || ',', ',' || scraper || ',', '') = ',' || CAST(sqlc.arg(hidden_scrapers) AS text) || ','
+  AND replace(',' || CAST(sqlc.arg(hidden_types) AS text) || ',', ',' || scraper || ':' || type || ',', '') = ',' || CAST(sqlc.arg(hidden_types) AS text) || ','
+ORDER BY id desc LIMIT 1000;
 
 -- name: UpdateStory :one
 UPDATE stories SET 
   title = $1, 
   url = $2, 
-  score = $3, 
-  num_comments = $4,
-  type = $5,
-  last_seen_fp = $6
-WHERE id = $7
+  canonical_url = $3,
+  score = $4, 
+  num_comments = $5,
+  type = $6,
+  last_seen_fp = $7
+WHERE id = $8
 RETURNING *;
 
 -- name: MarkStoryDeleted :one
@@ -21,10 +30,142 @@ SELECT * FROM stories WHERE scraper=$1 AND ref_id=$2;
 -- name: FindRecentForUpdate :many
 SELECT * FROM stories WHERE scraper=$1 AND created_at > $2 AND updated_at < $3  AND deleted=false;
 
+-- name: FindOriginalByCanonicalURL :one
+SELECT * FROM stories
+WHERE canonical_url = $1 AND scraper != $2 AND created_at > $3 AND duplicate_of IS NULL
+ORDER BY id LIMIT 1;
+
 -- name: CreateStory :one
 INSERT INTO stories (
-  ref_id, url, by, published_at, title, type, score, num_comments, scraper
+  ref_id, url, by, published_at, title, type, score, num_comments, scraper, canonical_url, duplicate_of
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
 )
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+-- name: GetStory :one
+SELECT * FROM stories WHERE id = $1;
+
+-- name: ListStoryHistory :many
+SELECT * FROM story_history WHERE story_id = $1 ORDER BY created_at, id;
+
+-- scrapers and types are comma separated lists, empty lists match everything.
+-- They are matched exactly like the hidden lists above, types as
+-- scraper:type. Duplicates are left out, unless the story they duplicate
+-- doesn't match the lists. Stories are ordered oldest first if ascending is
+-- set, else newest first.
+
+-- name: ListStories :many
+SELECT * FROM stories
+WHERE id > sqlc.arg(since) AND id < sqlc.arg(before)
+  AND (CAST(sqlc.arg(scrapers) AS text) = '' OR replace(',' || CAST(sqlc.arg(scrapers) AS text) || ',', ',' || scraper || ',', '') != ',' || CAST(sqlc.arg(scrapers) AS text) || ',')
+  AND (CAST(sqlc.arg(types) AS text) = '' OR replace(',' || CAST(sqlc.arg(types) AS text) || ',', ',' || scraper || ':' || type || ',', '') != ',' || CAST(sqlc.arg(types) AS text) || ',')
+  AND (duplicate_of IS NULL OR NOT EXISTS (
+    SELECT 1 FROM stories original
+    WHERE original.id = stories.duplicate_of
+      AND (CAST(sqlc.arg(scrapers) AS text) = '' OR replace(',' || CAST(sqlc.arg(scrapers) AS text) || ',', ',' || original.scraper || ',', '') != ',' || CAST(sqlc.arg(scrapers) AS text) || ',')
+      AND (CAST(sqlc.arg(types) AS text) = '' OR replace(',' || CAST(sqlc.arg(types) AS text) || ',', ',' || original.scraper || ':' || original.type || ',', '') != ',' || CAST(sqlc.arg(types) AS text) || ',')
+  ))
+ORDER BY CASE WHEN CAST(sqlc.arg(ascending) AS boolean) THEN id END ASC, id DESC
+LIMIT sqlc.arg(max_results);
+
+-- Deleted stories are left out, duplicates are grouped by GroupSearchResults.
+-- Types are matched as scraper:type like in ListStories.
+-- The match condition and the ranking depend on the dialect, see
+-- SearchStories in search.go, the comments mark where they are filled in.
+
+-- name: SearchStories :many
+SELECT * FROM stories
+WHERE deleted = false
+  AND /* match */ true
+  AND (sqlc.narg(created_from) IS NULL OR created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to) IS NULL OR created_at < sqlc.narg(created_to))
+  AND (CAST(sqlc.arg(scrapers) AS text) = '' OR replace(',' || CAST(sqlc.arg(scrapers) AS text) || ',', ',' || scraper || ',', '') != ',' || CAST(sqlc.arg(scrapers) AS text) || ',')
+  AND (CAST(sqlc.arg(types) AS text) = '' OR replace(',' || CAST(sqlc.arg(types) AS text) || ',', ',' || scraper || ':' || type || ',', '') != ',' || CAST(sqlc.arg(types) AS text) || ',')
+  AND (sqlc.narg(min_score) IS NULL OR score >= sqlc.narg(min_score))
+ORDER BY /* rank */ created_at DESC, id DESC
+LIMIT sqlc.arg(max_results) OFFSET sqlc.arg(result_offset);
+
+-- name: CreateSession :one
+INSERT INTO sessions (token, last_read, hidden_scrapers, hidden_types, show_muted, rewrite_links) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions WHERE token = $1;
+
+-- name: UpdateSessionLastRead :one
+UPDATE sessions SET last_read = $1, updated_at = $2 WHERE token = $3 RETURNING *;
+
+-- name: UpdateSessionFilters :one
+UPDATE sessions SET hidden_scrapers = $1, hidden_types = $2, show_muted = $3, rewrite_links = $4, updated_at = $5 WHERE token = $6 RETURNING *;
+
+-- name: ListMuteRules :many
+SELECT * FROM mute_rules ORDER BY kind, pattern;
+
+-- name: CreateMuteRule :one
+INSERT INTO mute_rules (kind, pattern) VALUES ($1, $2) RETURNING *;
+
+-- name: DeleteMuteRule :exec
+DELETE FROM mute_rules WHERE id = $1;
+
+-- name: CountStories :one
+SELECT count(*) FROM stories;
+
+-- name: CreateScrapeRun :one
+INSERT INTO scrape_runs (scraper, trigger, started_at, finished_at, new_count, updated_count, recent_count, error_count, errors)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- name: ListScrapeRuns :many
+SELECT * FROM scrape_runs
+WHERE (CAST(sqlc.arg(scraper) AS text) = '' OR scraper = CAST(sqlc.arg(scraper) AS text))
+  AND (NOT CAST(sqlc.arg(failed_only) AS boolean) OR error_count > 0)
+ORDER BY started_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: DeleteScrapeRunsBefore :exec
+DELETE FROM scrape_runs WHERE started_at < $1;
+
+-- name: ListStoriesWithoutArticle :many
+SELECT * FROM stories
+WHERE url != '' AND deleted = false AND duplicate_of IS NULL AND created_at > sqlc.arg(since)
+  AND NOT EXISTS (
+    SELECT 1 FROM articles WHERE articles.story_id = stories.id
+      -- articles to retry are due again once retry_before has passed
+      AND (articles.status != 'retry' OR articles.attempts >= sqlc.arg(max_attempts) OR articles.fetched_at > sqlc.arg(retry_before))
+  )
+ORDER BY id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: SaveArticle :one
+INSERT INTO articles (story_id, status, title, content, word_count, reading_minutes, error, fetched_at, attempts)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1)
+ON CONFLICT (story_id) DO UPDATE SET
+  attempts = articles.attempts + 1,
+  status = excluded.status,
+  title = excluded.title,
+  content = excluded.content,
+  word_count = excluded.word_count,
+  reading_minutes = excluded.reading_minutes,
+  error = excluded.error,
+  fetched_at = excluded.fetched_at
+RETURNING *;
+
+-- name: ListReadingTimes :many
+SELECT story_id, reading_minutes FROM articles
+WHERE status = 'ok' AND story_id BETWEEN sqlc.arg(min_id) AND sqlc.arg(max_id);
+
+-- name: GetArticle :one
+SELECT * FROM articles WHERE story_id = $1;
+
+-- name: HasCommentSnapshot :one
+SELECT EXISTS (SELECT 1 FROM comment_snapshots WHERE story_id = $1);
+
+-- name: SaveCommentSnapshot :one
+INSERT INTO comment_snapshots (story_id, taken_at, num_comments, tree)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (story_id) DO UPDATE SET
+  taken_at = excluded.taken_at,
+  num_comments = excluded.num_comments,
+  tree = excluded.tree
+RETURNING *;
+
+-- name: GetCommentSnapshot :one
+SELECT * FROM comment_snapshots WHERE story_id = $1;
@@ -0,0 +1,498 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+)
+
+// postgresTestURIEnv names the env var with the uri of a Postgres DB to run
+// the query tests against as well. All its tables are emptied, so it must be
+// a dedicated DB, see README
+const postgresTestURIEnv = "TEST_POSTGRES_URI"
+
+// testBackends returns a function per dialect that opens an empty, migrated
+// DB for a single test.
+func testBackends(t *testing.T) map[Dialect]func(t *testing.T) *DB {
+	backends := map[Dialect]func(t *testing.T) *DB{
+		DialectSQLite: func(t *testing.T) *DB {
+			return openTestDB(t, "sqlite://"+t.TempDir()+"/test.db")
+		},
+	}
+	if uri := os.Getenv(postgresTestURIEnv); uri != "" {
+		backends[DialectPostgres] = func(t *testing.T) *DB {
+			db := openTestDB(t, uri)
+			_, err := db.ExecContext(context.Background(), "truncate stories, story_history, sessions, mute_rules, scrape_runs, articles, comment_snapshots restart identity cascade")
+			if err != nil {
+				t.Fatalf("could not empty the test db: %v", err)
+			}
+			return db
+		}
+	} else {
+		t.Logf("%s is not set, skipping Postgres", postgresTestURIEnv)
+	}
+	return backends
+}
+
+func openTestDB(t *testing.T, uri string) *DB {
+	t.Helper()
+	db, err := InitDB(config.Config{DBURI: uri})
+	if err != nil {
+		t.Fatalf("could not open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newStory returns the params of a story, ref ids must be unique.
+func newStory(scraper, refID, url string) CreateStoryParams {
+	return CreateStoryParams{
+		RefID:        refID,
+		Url:          url,
+		By:           "someone",
+		PublishedAt:  time.Now().UTC().Truncate(time.Second),
+		Title:        "story " + refID,
+		Type:         "story",
+		Scraper:      scraper,
+		CanonicalUrl: CanonicalURL(url),
+	}
+}
+
+func duplicateOf(s Story) sql.NullInt64 {
+	return sql.NullInt64{Int64: s.ID, Valid: true}
+}
+
+func mustCreate(t *testing.T, q *Queries, params CreateStoryParams) Story {
+	t.Helper()
+	s, err := q.CreateStory(context.Background(), params)
+	if err != nil {
+		t.Fatalf("could not create story %s: %v", params.RefID, err)
+	}
+	return s
+}
+
+func refIDs(stories []Story) []string {
+	ids := make([]string, 0, len(stories))
+	for _, s := range stories {
+		ids = append(ids, s.RefID)
+	}
+	return ids
+}
+
+func wantRefIDs(t *testing.T, stories []Story, err error, want ...string) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := refIDs(stories); !slices.Equal(got, want) {
+		t.Errorf("got stories %v, want %v", got, want)
+	}
+}
+
+var queryTests = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, q *Queries)
+}{
+	{
+		name: "create and get story",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			params := newStory(ScraperHN, "1", "https://example.com/a")
+			created := mustCreate(t, q, params)
+			got, err := q.GetStory(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.RefID != params.RefID || got.Url != params.Url || got.Scraper != params.Scraper ||
+				got.CanonicalUrl != params.CanonicalUrl || got.DuplicateOf.Valid || got.Deleted {
+				t.Errorf("got %+v, want it created from %+v", got, params)
+			}
+			if !got.PublishedAt.Equal(params.PublishedAt) {
+				t.Errorf("published at %s, want %s", got.PublishedAt, params.PublishedAt)
+			}
+			if _, err := q.GetStory(ctx, created.ID+1); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("got error %v for unknown story, want sql.ErrNoRows", err)
+			}
+			found, err := q.FindByScraperAndRef(ctx, FindByScraperAndRefParams{Scraper: ScraperHN, RefID: "1"})
+			wantRefIDs(t, found, err, "1")
+			n, err := q.CountStories(ctx)
+			if err != nil || n != 1 {
+				t.Errorf("got %d stories, %v, want 1", n, err)
+			}
+		},
+	},
+	{
+		name: "update story records history",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			s := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			updated, err := q.UpdateStory(ctx, UpdateStoryParams{
+				ID:           s.ID,
+				Title:        "new title",
+				Url:          s.Url,
+				CanonicalUrl: s.CanonicalUrl,
+				Score:        s.Score + 10,
+				NumComments:  s.NumComments,
+				Type:         s.Type,
+				LastSeenFp:   time.Now(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Title != "new title" || updated.Score != s.Score+10 {
+				t.Errorf("got %+v after update", updated)
+			}
+			history, err := q.ListStoryHistory(ctx, s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := []string{}
+			for _, h := range history {
+				fields = append(fields, h.Field)
+			}
+			slices.Sort(fields)
+			if !slices.Equal(fields, []string{"score", "title"}) {
+				t.Errorf("got history of %v, want score and title", fields)
+			}
+			deleted, err := q.MarkStoryDeleted(ctx, s.ID)
+			if err != nil || !deleted.Deleted {
+				t.Errorf("got %+v, %v after delete", deleted, err)
+			}
+		},
+	},
+	{
+		name: "find recent for update",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			gone := mustCreate(t, q, newStory(ScraperHN, "2", "https://example.com/b"))
+			mustCreate(t, q, newStory(ScraperLobsters, "3", "https://example.com/c"))
+			if _, err := q.MarkStoryDeleted(ctx, gone.ID); err != nil {
+				t.Fatal(err)
+			}
+			stories, err := q.FindRecentForUpdate(ctx, FindRecentForUpdateParams{
+				Scraper:   ScraperHN,
+				CreatedAt: time.Now().Add(-time.Hour),
+				UpdatedAt: time.Now().Add(time.Hour),
+			})
+			wantRefIDs(t, stories, err, "1")
+			stories, err = q.FindRecentForUpdate(ctx, FindRecentForUpdateParams{
+				Scraper:   ScraperHN,
+				CreatedAt: time.Now().Add(-time.Hour),
+				UpdatedAt: time.Now().Add(-time.Hour),
+			})
+			wantRefIDs(t, stories, err)
+		},
+	},
+	{
+		name: "find original by canonical url",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			original := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a?utm_source=hn"))
+			dup := newStory(ScraperLobsters, "2", "https://www.example.com/a/")
+			dup.DuplicateOf = duplicateOf(original)
+			mustCreate(t, q, dup)
+
+			tests := []struct {
+				name    string
+				params  FindOriginalByCanonicalURLParams
+				wantRef string
+			}{
+				{"other scraper", FindOriginalByCanonicalURLParams{CanonicalUrl: "https://example.com/a", Scraper: ScraperReddit, CreatedAt: time.Now().Add(-time.Hour)}, "1"},
+				{"same scraper", FindOriginalByCanonicalURLParams{CanonicalUrl: "https://example.com/a", Scraper: ScraperHN, CreatedAt: time.Now().Add(-time.Hour)}, ""},
+				{"too old", FindOriginalByCanonicalURLParams{CanonicalUrl: "https://example.com/a", Scraper: ScraperReddit, CreatedAt: time.Now().Add(time.Hour)}, ""},
+				{"other url", FindOriginalByCanonicalURLParams{CanonicalUrl: "https://example.com/b", Scraper: ScraperReddit, CreatedAt: time.Now().Add(-time.Hour)}, ""},
+			}
+			for _, tt := range tests {
+				s, err := q.FindOriginalByCanonicalURL(ctx, tt.params)
+				if tt.wantRef == "" {
+					if !errors.Is(err, sql.ErrNoRows) {
+						t.Errorf("%s: got %+v, %v, want no story", tt.name, s, err)
+					}
+					continue
+				}
+				if err != nil || s.RefID != tt.wantRef {
+					t.Errorf("%s: got %+v, %v, want story %s", tt.name, s, err, tt.wantRef)
+				}
+			}
+		},
+	},
+	{
+		name: "list stories beginning at with hidden scrapers and types",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			first := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			ask := newStory(ScraperHN, "2", "")
+			ask.Type = TypeHNAskHN
+			mustCreate(t, q, ask)
+			mustCreate(t, q, newStory("feed:my_blog", "3", "https://example.com/c"))
+			mustCreate(t, q, newStory("feed:myXblog", "4", "https://example.com/d"))
+
+			tests := []struct {
+				name   string
+				params ListStoriesBeginningAtParams
+				want   []string
+			}{
+				{"nothing hidden", ListStoriesBeginningAtParams{}, []string{"4", "3", "2", "1"}},
+				{"from id", ListStoriesBeginningAtParams{ID: first.ID + 2}, []string{"4", "3"}},
+				{"hidden scrapers", ListStoriesBeginningAtParams{HiddenScrapers: "hn,lobsters"}, []string{"4", "3"}},
+				{"no wildcards", ListStoriesBeginningAtParams{HiddenScrapers: "feed:my_blog"}, []string{"4", "2", "1"}},
+				{"no prefixes", ListStoriesBeginningAtParams{HiddenScrapers: "h,feed"}, []string{"4", "3", "2", "1"}},
+				{"hidden types", ListStoriesBeginningAtParams{HiddenTypes: TypeFilterKey(ScraperHN, TypeHNAskHN) + ",lobsters:story"}, []string{"4", "3", "1"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					stories, err := q.ListStoriesBeginningAt(ctx, tt.params)
+					wantRefIDs(t, stories, err, tt.want...)
+				})
+			}
+		},
+	},
+	{
+		name: "list stories in both directions with filters and duplicates",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			original := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			dup := newStory(ScraperLobsters, "2", "https://example.com/a")
+			dup.DuplicateOf = duplicateOf(original)
+			mustCreate(t, q, dup)
+			ask := newStory(ScraperLobsters, "3", "https://example.com/b")
+			ask.Type = TypeLobstersAsk
+			mustCreate(t, q, ask)
+			mustCreate(t, q, newStory("feed:my_blog", "4", "https://example.com/c"))
+
+			tests := []struct {
+				name          string
+				scrapers      string
+				types         string
+				before, after []string
+			}{
+				{"no filter", "", "", []string{"4", "3", "1"}, []string{"1", "3", "4"}},
+				{"duplicate of filtered out story", "lobsters", "", []string{"3", "2"}, []string{"2", "3"}},
+				{"duplicate of listed story", "hn,lobsters", "", []string{"3", "1"}, []string{"1", "3"}},
+				{"types", "", TypeFilterKey(ScraperLobsters, TypeLobstersAsk), []string{"3"}, []string{"3"}},
+				{"bare types", "", TypeLobstersAsk, []string{}, []string{}},
+				{"type of another scraper", "", TypeFilterKey(ScraperHN, TypeLobstersAsk), []string{}, []string{}},
+				{"duplicate of story of listed type", "", TypeFilterKey(ScraperHN, TypeHNStory), []string{"1"}, []string{"1"}},
+				{"duplicate of story of filtered out type", "", TypeFilterKey(ScraperLobsters, TypeLobstersStory), []string{"2"}, []string{"2"}},
+				{"no wildcards", "feed:myXblog", "", []string{}, []string{}},
+				{"exact", "feed:my_blog", "", []string{"4"}, []string{"4"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					before, err := q.ListStories(ctx, ListStoriesParams{
+						Since: 0, Before: math.MaxInt64, Scrapers: tt.scrapers, Types: tt.types, MaxResults: 10,
+					})
+					wantRefIDs(t, before, err, tt.before...)
+					after, err := q.ListStories(ctx, ListStoriesParams{
+						Since: 0, Before: math.MaxInt64, Scrapers: tt.scrapers, Types: tt.types, Ascending: true, MaxResults: 10,
+					})
+					wantRefIDs(t, after, err, tt.after...)
+				})
+			}
+
+			page, err := q.ListStories(ctx, ListStoriesParams{Since: original.ID, Before: math.MaxInt64, MaxResults: 1})
+			wantRefIDs(t, page, err, "4")
+			page, err = q.ListStories(ctx, ListStoriesParams{Since: original.ID, Before: math.MaxInt64, Ascending: true, MaxResults: 1})
+			wantRefIDs(t, page, err, "3")
+		},
+	},
+	{
+		name: "sessions",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			created, err := q.CreateSession(ctx, CreateSessionParams{Token: "token", LastRead: 5, HiddenScrapers: "lobsters", RewriteLinks: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.LastRead != 5 || created.HiddenScrapers != "lobsters" || !created.RewriteLinks || created.ShowMuted {
+				t.Errorf("got %+v after create", created)
+			}
+			if _, err := q.UpdateSessionLastRead(ctx, UpdateSessionLastReadParams{Token: "token", LastRead: 9, UpdatedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := q.UpdateSessionFilters(ctx, UpdateSessionFiltersParams{Token: "token", HiddenTypes: "hn:job", ShowMuted: true, UpdatedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := q.GetSession(ctx, "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.LastRead != 9 || got.HiddenScrapers != "" || got.HiddenTypes != "hn:job" || !got.ShowMuted || got.RewriteLinks {
+				t.Errorf("got %+v after updates", got)
+			}
+			if _, err := q.GetSession(ctx, "unknown"); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("got error %v for unknown session, want sql.ErrNoRows", err)
+			}
+		},
+	},
+	{
+		name: "mute rules",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			for _, p := range []CreateMuteRuleParams{
+				{Kind: MuteKindTitle, Pattern: "crypto"},
+				{Kind: MuteKindDomain, Pattern: "example.com"},
+				{Kind: MuteKindTitle, Pattern: "ai"},
+			} {
+				if _, err := q.CreateMuteRule(ctx, p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rules, err := q.ListMuteRules(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, r := range rules {
+				got = append(got, r.Kind+":"+r.Pattern)
+			}
+			want := []string{"domain:example.com", "title:ai", "title:crypto"}
+			if !slices.Equal(got, want) {
+				t.Errorf("got rules %v, want %v", got, want)
+			}
+			if err := q.DeleteMuteRule(ctx, rules[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			if rules, err = q.ListMuteRules(ctx); err != nil || len(rules) != 2 {
+				t.Errorf("got %d rules, %v after delete, want 2", len(rules), err)
+			}
+		},
+	},
+	{
+		name: "scrape runs",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			start := time.Now().UTC().Truncate(time.Second)
+			for i, p := range []CreateScrapeRunParams{
+				{Scraper: ScraperHN, Trigger: "schedule", NewCount: 3},
+				{Scraper: ScraperLobsters, Trigger: "schedule", ErrorCount: 1, Errors: "timeout"},
+				{Scraper: ScraperHN, Trigger: "manual", ErrorCount: 2, Errors: "a\nb"},
+			} {
+				p.StartedAt = start.Add(time.Duration(i) * time.Minute)
+				p.FinishedAt = p.StartedAt.Add(time.Second)
+				if _, err := q.CreateScrapeRun(ctx, p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				params ListScrapeRunsParams
+				want   []string
+			}{
+				{ListScrapeRunsParams{MaxResults: 10}, []string{"hn:manual", "lobsters:schedule", "hn:schedule"}},
+				{ListScrapeRunsParams{Scraper: ScraperHN, MaxResults: 10}, []string{"hn:manual", "hn:schedule"}},
+				{ListScrapeRunsParams{FailedOnly: true, MaxResults: 10}, []string{"hn:manual", "lobsters:schedule"}},
+				{ListScrapeRunsParams{MaxResults: 1}, []string{"hn:manual"}},
+			}
+			for _, tt := range tests {
+				runs, err := q.ListScrapeRuns(ctx, tt.params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []string{}
+				for _, r := range runs {
+					got = append(got, r.Scraper+":"+r.Trigger)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%+v: got runs %v, want %v", tt.params, got, tt.want)
+				}
+			}
+
+			if err := q.DeleteScrapeRunsBefore(ctx, start.Add(90*time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if runs, err := q.ListScrapeRuns(ctx, ListScrapeRunsParams{MaxResults: 10}); err != nil || len(runs) != 1 {
+				t.Errorf("got %d runs, %v after delete, want 1", len(runs), err)
+			}
+		},
+	},
+	{
+		name: "articles",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			ok := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			failed := mustCreate(t, q, newStory(ScraperHN, "2", "https://example.com/b"))
+			retry := mustCreate(t, q, newStory(ScraperHN, "3", "https://example.com/c"))
+			mustCreate(t, q, newStory(ScraperHN, "4", ""))
+			dup := newStory(ScraperLobsters, "5", "https://example.com/a")
+			dup.DuplicateOf = duplicateOf(ok)
+			mustCreate(t, q, dup)
+			mustCreate(t, q, newStory(ScraperHN, "6", "https://example.com/d"))
+
+			longAgo := time.Now().Add(-2 * time.Hour)
+			for _, p := range []SaveArticleParams{
+				{StoryID: ok.ID, Status: ArticleOK, Title: "a", Content: "one\n\ntwo", WordCount: 2, ReadingMinutes: 1, FetchedAt: longAgo},
+				{StoryID: failed.ID, Status: ArticleFailed, Error: "404", FetchedAt: longAgo},
+				{StoryID: retry.ID, Status: ArticleRetry, Error: "timeout", FetchedAt: longAgo},
+			} {
+				if _, err := q.SaveArticle(ctx, p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			list := func(retryBefore time.Time, maxAttempts int32) ([]Story, error) {
+				return q.ListStoriesWithoutArticle(ctx, ListStoriesWithoutArticleParams{
+					Since:       time.Now().Add(-time.Hour),
+					MaxAttempts: maxAttempts,
+					RetryBefore: retryBefore,
+					MaxResults:  10,
+				})
+			}
+			stories, err := list(time.Now().Add(-time.Hour), 3)
+			wantRefIDs(t, stories, err, "6", "3")
+			stories, err = list(time.Now().Add(-3*time.Hour), 3)
+			wantRefIDs(t, stories, err, "6")
+			stories, err = list(time.Now().Add(-time.Hour), 1)
+			wantRefIDs(t, stories, err, "6")
+
+			saved, err := q.SaveArticle(ctx, SaveArticleParams{StoryID: retry.ID, Status: ArticleOK, Content: "text", FetchedAt: time.Now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Status != ArticleOK || saved.Error != "" || saved.Attempts != 2 {
+				t.Errorf("got %+v after saving again", saved)
+			}
+			got, err := q.GetArticle(ctx, ok.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got.Paragraphs(), []string{"one", "two"}) || got.Attempts != 1 {
+				t.Errorf("got %+v", got)
+			}
+		},
+	},
+	{
+		name: "comment snapshots",
+		run: func(t *testing.T, ctx context.Context, q *Queries) {
+			s := mustCreate(t, q, newStory(ScraperHN, "1", "https://example.com/a"))
+			if has, err := q.HasCommentSnapshot(ctx, s.ID); err != nil || has {
+				t.Errorf("got %t, %v before saving, want false", has, err)
+			}
+			for _, n := range []int32{3, 5} {
+				if _, err := q.SaveCommentSnapshot(ctx, SaveCommentSnapshotParams{StoryID: s.ID, TakenAt: time.Now(), NumComments: n, Tree: "[]"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if has, err := q.HasCommentSnapshot(ctx, s.ID); err != nil || !has {
+				t.Errorf("got %t, %v after saving, want true", has, err)
+			}
+			got, err := q.GetCommentSnapshot(ctx, s.ID)
+			if err != nil || got.NumComments != 5 || got.Tree != "[]" {
+				t.Errorf("got %+v, %v, want the latest snapshot", got, err)
+			}
+		},
+	},
+}
+
+// TestQueries runs the same tests against every dialect, the queries are
+// written once for both.
+func TestQueries(t *testing.T) {
+	for dialect, open := range testBackends(t) {
+		t.Run(string(dialect), func(t *testing.T) {
+			for _, tt := range queryTests {
+				t.Run(tt.name, func(t *testing.T) {
+					db := open(t)
+					tt.run(t, context.Background(), db.Queries())
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchParams filter the stories of a search, zero values don't filter.
+type SearchParams struct {
+	// Query is in web search syntax: words, "quoted phrases", or and -excluded
+	Query    string
+	From     time.Time
+	To       time.Time
+	Scrapers []string
+	Types    []string
+	MinScore int32
+	Limit    int32
+	Offset   int32
+}
+
+// the comments in the SearchStories query replaced by the match condition and
+// the ranking
+const (
+	searchMatchMarker = "/* match */ true"
+	searchRankMarker  = "/* rank */"
+)
+
+// searchDBTX fills in the match condition and ranking of the SearchStories
+// query, they depend on the dialect. Its arguments are appended to the ones
+// of the query.
+type searchDBTX struct {
+	DBTX
+	dialect Dialect
+	query   string
+}
+
+func (s searchDBTX) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if !strings.Contains(query, searchMatchMarker) || !strings.Contains(query, searchRankMarker) {
+		return nil, fmt.Errorf("search query is missing %q or %q", searchMatchMarker, searchRankMarker)
+	}
+	match, rank := "true", ""
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	switch {
+	case s.query == "":
+	case s.dialect == DialectPostgres:
+		tsQuery := "websearch_to_tsquery('english', " + arg(s.query) + ")"
+		match = "(story_search_vector(title, by, url) @@ " + tsQuery +
+			" OR EXISTS (SELECT 1 FROM articles a WHERE a.story_id = stories.id AND to_tsvector('english', a.content) @@ " + tsQuery + "))"
+		rank = "ts_rank(story_search_vector(title, by, url), " + tsQuery + ") DESC, "
+	default:
+		conds := []string{}
+		for _, term := range searchTerms(s.query) {
+			like := arg("%" + escapeLike(term) + "%")
+			conds = append(conds, "(title LIKE "+like+" ESCAPE '\\' OR url LIKE "+like+" ESCAPE '\\' OR by LIKE "+like+" ESCAPE '\\'"+
+				" OR EXISTS (SELECT 1 FROM articles a WHERE a.story_id = stories.id AND a.content LIKE "+like+" ESCAPE '\\'))")
+		}
+		if len(conds) > 0 {
+			match = strings.Join(conds, " AND ")
+		}
+	}
+	query = strings.Replace(query, searchMatchMarker, match, 1)
+	query = strings.Replace(query, searchRankMarker, rank, 1)
+	return s.DBTX.QueryContext(ctx, query, args...)
+}
+
+// SearchStories returns the stories matching p. Postgres uses its full-text
+// search on title, domain, author and the fetched article and ranks by
+// relevance, stories matching only in the article come last. SQLite has no
+// full-text search in the default build, there every word has to appear in
+// title, url, author or article and the newest stories come first.
+func (db *DB) SearchStories(ctx context.Context, p SearchParams) ([]Story, error) {
+	params := SearchStoriesParams{
+		CreatedFrom:  sql.NullTime{Time: p.From, Valid: !p.From.IsZero()},
+		CreatedTo:    sql.NullTime{Time: p.To, Valid: !p.To.IsZero()},
+		Scrapers:     strings.Join(p.Scrapers, ","),
+		Types:        strings.Join(p.Types, ","),
+		MinScore:     sql.NullInt32{Int32: p.MinScore, Valid: p.MinScore > 0},
+		MaxResults:   p.Limit,
+		ResultOffset: p.Offset,
+	}
+	// the match is filled in first, so its placeholders are rewritten for
+	// SQLite as well
+	search := searchDBTX{DBTX: db.DBTX(db.DB), dialect: db.Dialect, query: p.Query}
+	return New(timedDBTX{db: search}).SearchStories(ctx, params)
+}
+
+// GroupSearchResults folds duplicates into their original if it is one of
+// the results, otherwise the first duplicate takes its place.
+func GroupSearchResults(stories []Story) []StoryGroup {
+	missing := map[int64]bool{}
+	for _, id := range MissingOriginals(stories) {
+		missing[id] = true
+	}
+	return GroupDuplicates(stories, missing)
+}
+
+// searchTerms splits the query into words, operators of the web search syntax
+// are dropped.
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, t := range strings.Fields(query) {
+		t = strings.Trim(t, `"-`)
+		if t != "" && !strings.EqualFold(t, "or") {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
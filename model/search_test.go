@@ -0,0 +1,117 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchStories(t *testing.T) {
+	for dialect, open := range testBackends(t) {
+		t.Run(string(dialect), func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t)
+			q := db.Queries()
+
+			original := newStory(ScraperHN, "1", "https://example.com/go")
+			original.Title = "Go 1.23 is released"
+			original.Score = 100
+			o := mustCreate(t, q, original)
+			dup := newStory(ScraperLobsters, "2", "https://example.com/go")
+			dup.Title = "Go 1.23 is released"
+			dup.DuplicateOf = duplicateOf(o)
+			mustCreate(t, q, dup)
+			gone := newStory(ScraperHN, "3", "https://example.com/gone")
+			gone.Title = "Go is gone"
+			deleted := mustCreate(t, q, gone)
+			if _, err := q.MarkStoryDeleted(ctx, deleted.ID); err != nil {
+				t.Fatal(err)
+			}
+			rust := newStory(ScraperLobsters, "4", "https://example.com/rust")
+			rust.Title = "Rust news"
+			rust.Score = 50
+			mustCreate(t, q, rust)
+			other := newStory(ScraperHN, "5", "https://example.com/other")
+			other.Title = "Unrelated"
+			withArticle := mustCreate(t, q, other)
+			if _, err := q.SaveArticle(ctx, SaveArticleParams{StoryID: withArticle.ID, Status: ArticleOK, Content: "a golang tutorial", FetchedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+
+			tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+			tests := []struct {
+				name   string
+				params SearchParams
+				// sorted, the order depends on the dialect
+				want []string
+			}{
+				{"everything but deleted", SearchParams{}, []string{"1", "2", "4", "5"}},
+				{"query", SearchParams{Query: "released"}, []string{"1", "2"}},
+				{"query in article", SearchParams{Query: "golang"}, []string{"5"}},
+				{"scrapers", SearchParams{Query: "released", Scrapers: []string{ScraperLobsters}}, []string{"2"}},
+				{"types", SearchParams{Types: []string{TypeFilterKey(ScraperLobsters, TypeLobstersStory)}}, []string{"2", "4"}},
+				{"bare types", SearchParams{Types: []string{TypeLobstersStory}}, []string{}},
+				{"min score", SearchParams{MinScore: 50}, []string{"1", "4"}},
+				{"from", SearchParams{From: tomorrow}, []string{}},
+				{"to", SearchParams{To: tomorrow}, []string{"1", "2", "4", "5"}},
+				{"limit and offset", SearchParams{Limit: 1, Offset: 1}, []string{"4"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if tt.params.Limit == 0 {
+						tt.params.Limit = 10
+					}
+					stories, err := db.SearchStories(ctx, tt.params)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got := refIDs(stories)
+					slices.Sort(got)
+					if !slices.Equal(got, tt.want) {
+						t.Errorf("got stories %v, want %v", got, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestGroupSearchResults(t *testing.T) {
+	story := func(id, duplicateOf int64) Story {
+		return Story{ID: id, DuplicateOf: sql.NullInt64{Int64: duplicateOf, Valid: duplicateOf != 0}}
+	}
+	tests := []struct {
+		name    string
+		stories []Story
+		// head ids with the ids of their duplicates
+		want [][]int64
+	}{
+		{
+			name:    "original in results",
+			stories: []Story{story(4, 1), story(3, 0), story(2, 1), story(1, 0)},
+			want:    [][]int64{{3}, {1, 4, 2}},
+		},
+		{
+			name:    "original not in results",
+			stories: []Story{story(5, 9), story(3, 0), story(2, 9)},
+			want:    [][]int64{{5, 2}, {3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][]int64{}
+			for _, g := range GroupSearchResults(tt.stories) {
+				ids := []int64{g.ID}
+				for _, d := range g.Duplicates {
+					ids = append(ids, d.ID)
+				}
+				got = append(got, ids)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+var scraperTypes = map[string][]string{
+	ScraperHN:       {TypeHNStory, TypeHNAskHN, TypeHNShowHN, TypeHNJob},
+	ScraperLobsters: {TypeLobstersStory, TypeLobstersAsk, TypeLobstersShow},
+	ScraperReddit:   {TypeRedditLink, TypeRedditSelf},
+}
+
+// ScraperTypes returns the types of the stories the given scraper creates.
+func ScraperTypes(scraper string) []string {
+	if IsFeedScraper(scraper) {
+		return []string{TypeFeedEntry}
+	}
+	return scraperTypes[scraper]
+}
+
+// TypeFilterKey identifies a type of a scraper in the hidden types of a
+// session, types like "story" are used by multiple scrapers.
+func TypeFilterKey(scraper, typ string) string {
+	return scraper + ":" + typ
+}
+
+// ShowsScraper reports whether the reader wants to see stories of the scraper.
+func (s *Session) ShowsScraper(scraper string) bool {
+	return !slices.Contains(strings.Split(s.HiddenScrapers, ","), scraper)
+}
+
+// Shows reports whether the reader wants to see the story.
+func (s *Session) Shows(story *Story) bool {
+	return s.ShowsScraper(story.Scraper) && s.ShowsType(story.Scraper, story.Type)
+}
+
+// ShowsType reports whether the reader wants to see stories of the given type
+// of the scraper.
+func (s *Session) ShowsType(scraper, typ string) bool {
+	return !slices.Contains(strings.Split(s.HiddenTypes, ","), TypeFilterKey(scraper, typ))
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+)
+
+// name of the registered SQLite driver, empty if built without cgo
+var sqliteDriver = ""
+
+var sqliteDefaultParams = map[string]string{
+	"_foreign_keys": "on",
+	"_busy_timeout": "5000",
+	"_journal_mode": "WAL",
+	// take the write lock when the transaction starts, so concurrent
+	// migrations can't both see a migration as pending
+	"_txlock": "immediate",
+}
+
+// matches the numbered placeholders used in the Postgres queries
+var placeholderRegexp = regexp.MustCompile(`\$(\d+)`)
+
+// format of timestamps in the SQLite schema, see migrations/sqlite
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+// sqliteDBTX runs the queries generated by sqlc for Postgres against SQLite.
+// Placeholders are rewritten from $N to ?N and times are stored as UTC text,
+// so they compare correctly.
+type sqliteDBTX struct {
+	db DBTX
+}
+
+func rebindSQLite(query string) string {
+	return placeholderRegexp.ReplaceAllString(query, "?$1")
+}
+
+func sqliteArgs(args []any) []any {
+	converted := make([]any, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case time.Time:
+			converted[i] = v.UTC().Format(sqliteTimeFormat)
+		case sql.NullTime:
+			if v.Valid {
+				converted[i] = v.Time.UTC().Format(sqliteTimeFormat)
+			} else {
+				converted[i] = nil
+			}
+		default:
+			converted[i] = a
+		}
+	}
+	return converted
+}
+
+func (s sqliteDBTX) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, rebindSQLite(query), sqliteArgs(args)...)
+}
+
+func (s sqliteDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.db.PrepareContext(ctx, rebindSQLite(query))
+}
+
+func (s sqliteDBTX) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, rebindSQLite(query), sqliteArgs(args)...)
+}
+
+func (s sqliteDBTX) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, rebindSQLite(query), sqliteArgs(args)...)
+}
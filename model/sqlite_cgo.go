@@ -0,0 +1,11 @@
+//go:build cgo
+
+package model
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	sqliteDriver = "sqlite3"
+}
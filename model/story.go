@@ -50,6 +50,8 @@ func (s *Story) TimeOnFP() string {
 	return fmt.Sprintf(", for %dh", hoursOnFP)
 }
 
+// LinkURL returns the link of the story as published, see RewrittenURL for the
+// link after the URL transformers.
 func (s *Story) LinkURL() string {
 	u := "#"
 
@@ -61,30 +63,59 @@ func (s *Story) LinkURL() string {
 		default:
 			u = s.Url
 		}
+	case ScraperLobsters:
+		u = s.Url
+		if u == "" {
+			u = s.CommentsURL()
+		}
+	case ScraperReddit:
+		switch s.Type {
+		case TypeRedditSelf:
+			u = s.CommentsURL()
+		default:
+			u = s.Url
+		}
+	default:
+		if IsFeedScraper(s.Scraper) && s.Url != "" {
+			u = s.Url
+		}
 	}
 
+	return u
+}
+
+// RewrittenURL returns LinkURL rewritten by the first matching URL
+// transformer, empty if none matches.
+func (s *Story) RewrittenURL() string {
+	u := s.LinkURL()
 	if u == "#" {
-		return u
+		return ""
 	}
 
 	pu, err := url.Parse(u)
 	if err != nil {
-		return u
+		return ""
 	}
 
-	for _, t := range urlTransformer {
+	for _, t := range getURLTransformers() {
 		if t.Matches(pu) {
-			return t.Transform(pu).String()
+			if r := t.Transform(pu).String(); r != u {
+				return r
+			}
+			return ""
 		}
 	}
-
-	return u
+	return ""
 }
 
 func (s *Story) SearchURL() string {
 	switch s.Scraper {
 	case ScraperHN:
 		return "https://hn.algolia.com/?dateRange=pastYear&type=story&query=" + url.QueryEscape(s.Title)
+	case ScraperLobsters:
+		return "https://lobste.rs/search?what=stories&order=relevance&q=" + url.QueryEscape(s.Title)
+	case ScraperReddit:
+		return "https://www.reddit.com/search/?type=link&q=" + url.QueryEscape(s.Title)
 	default:
 		return "#"
 	}
@@ -94,6 +125,10 @@ func (s *Story) CommentsURL() string {
 	switch s.Scraper {
 	case ScraperHN:
 		return "https://news.ycombinator.com/item?id=" + url.QueryEscape(s.RefID)
+	case ScraperLobsters:
+		return "https://lobste.rs/s/" + url.PathEscape(s.RefID)
+	case ScraperReddit:
+		return "https://www.reddit.com/comments/" + url.PathEscape(s.RefID)
 	default:
 		return "#"
 	}
@@ -0,0 +1,72 @@
+package model
+
+// StoryGroup is a story together with the stories from other sources that
+// link to the same article.
+type StoryGroup struct {
+	Story
+	Duplicates []Story
+	// Muted is set when the story matches a mute rule and the reader wants
+	// to see muted stories collapsed
+	Muted bool
+	// ReadingMinutes is the reading time of the fetched article, zero if
+	// there is none
+	ReadingMinutes int32
+}
+
+// LatestID returns the highest id of the story and its duplicates.
+func (g *StoryGroup) LatestID() int64 {
+	id := g.ID
+	for _, d := range g.Duplicates {
+		id = max(id, d.ID)
+	}
+	return id
+}
+
+// GroupDuplicates folds duplicates into the story they duplicate, keeping
+// the order of stories. Duplicates whose original is not part of stories are
+// dropped, the original has been listed before. If the original is in
+// hiddenOriginals instead, because the reader hides its source or type, the
+// first of its duplicates takes its place.
+func GroupDuplicates(stories []Story, hiddenOriginals map[int64]bool) []StoryGroup {
+	groups := make([]StoryGroup, 0, len(stories))
+	// groups by the id of their original
+	index := map[int64]int{}
+	for _, s := range stories {
+		switch {
+		case !s.DuplicateOf.Valid:
+			index[s.ID] = len(groups)
+			groups = append(groups, StoryGroup{Story: s})
+		case hiddenOriginals[s.DuplicateOf.Int64]:
+			if _, ok := index[s.DuplicateOf.Int64]; !ok {
+				index[s.DuplicateOf.Int64] = len(groups)
+				groups = append(groups, StoryGroup{Story: s})
+			}
+		}
+	}
+	for _, s := range stories {
+		if !s.DuplicateOf.Valid {
+			continue
+		}
+		if i, ok := index[s.DuplicateOf.Int64]; ok && groups[i].ID != s.ID {
+			groups[i].Duplicates = append(groups[i].Duplicates, s)
+		}
+	}
+	return groups
+}
+
+// MissingOriginals returns the ids of the stories duplicated by stories that
+// are not part of stories themselves.
+func MissingOriginals(stories []Story) []int64 {
+	listed := map[int64]bool{}
+	for _, s := range stories {
+		listed[s.ID] = true
+	}
+	ids := []int64{}
+	for _, s := range stories {
+		if s.DuplicateOf.Valid && !listed[s.DuplicateOf.Int64] {
+			listed[s.DuplicateOf.Int64] = true
+			ids = append(ids, s.DuplicateOf.Int64)
+		}
+	}
+	return ids
+}
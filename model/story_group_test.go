@@ -0,0 +1,70 @@
+package model
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+)
+
+func TestGroupDuplicates(t *testing.T) {
+	story := func(id, duplicateOf int64) Story {
+		return Story{ID: id, DuplicateOf: sql.NullInt64{Int64: duplicateOf, Valid: duplicateOf != 0}}
+	}
+	tests := []struct {
+		name            string
+		stories         []Story
+		hiddenOriginals map[int64]bool
+		// head ids with the ids of their duplicates
+		want [][]int64
+	}{
+		{
+			name:    "original listed",
+			stories: []Story{story(4, 1), story(3, 0), story(2, 1), story(1, 0)},
+			want:    [][]int64{{3}, {1, 4, 2}},
+		},
+		{
+			name:    "original listed before",
+			stories: []Story{story(4, 1), story(3, 0), story(2, 1)},
+			want:    [][]int64{{3}},
+		},
+		{
+			name:            "original hidden",
+			stories:         []Story{story(4, 1), story(3, 0), story(2, 1)},
+			hiddenOriginals: map[int64]bool{1: true},
+			want:            [][]int64{{4, 2}, {3}},
+		},
+		{
+			name:            "original hidden and listed before",
+			stories:         []Story{story(5, 2), story(4, 1), story(3, 0)},
+			hiddenOriginals: map[int64]bool{1: true},
+			want:            [][]int64{{4}, {3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][]int64{}
+			for _, g := range GroupDuplicates(tt.stories, tt.hiddenOriginals) {
+				ids := []int64{g.ID}
+				for _, d := range g.Duplicates {
+					ids = append(ids, d.ID)
+				}
+				got = append(got, ids)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingOriginals(t *testing.T) {
+	stories := []Story{
+		{ID: 5, DuplicateOf: sql.NullInt64{Int64: 1, Valid: true}},
+		{ID: 4, DuplicateOf: sql.NullInt64{Int64: 3, Valid: true}},
+		{ID: 3},
+		{ID: 2, DuplicateOf: sql.NullInt64{Int64: 1, Valid: true}},
+	}
+	if got := MissingOriginals(stories); !slices.Equal(got, []int64{1}) {
+		t.Errorf("got %v, want [1]", got)
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// StoryJSON is the representation of a story in the API, including the
+// computed fields used by the views.
+type StoryJSON struct {
+	ID           int64     `json:"id"`
+	RefID        string    `json:"ref_id"`
+	Scraper      string    `json:"scraper"`
+	Type         string    `json:"type"`
+	Title        string    `json:"title"`
+	Url          string    `json:"url"`
+	CanonicalUrl string    `json:"canonical_url,omitempty"`
+	Domain       string    `json:"domain"`
+	LinkURL      string    `json:"link_url"`
+	RewrittenURL string    `json:"rewritten_url,omitempty"`
+	CommentsURL  string    `json:"comments_url"`
+	By           string    `json:"by"`
+	Score        int32     `json:"score"`
+	NumComments  int32     `json:"num_comments"`
+	Deleted      bool      `json:"deleted"`
+	DuplicateOf  *int64    `json:"duplicate_of,omitempty"`
+	PublishedAt  time.Time `json:"published_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	LastSeenFp   time.Time `json:"last_seen_fp"`
+}
+
+func (s *Story) JSON() StoryJSON {
+	j := StoryJSON{
+		ID:           s.ID,
+		RefID:        s.RefID,
+		Scraper:      s.Scraper,
+		Type:         s.Type,
+		Title:        s.Title,
+		Url:          s.Url,
+		CanonicalUrl: s.CanonicalUrl,
+		Domain:       s.Domain(),
+		LinkURL:      s.LinkURL(),
+		RewrittenURL: s.RewrittenURL(),
+		CommentsURL:  s.CommentsURL(),
+		By:           s.By,
+		Score:        s.Score,
+		NumComments:  s.NumComments,
+		Deleted:      s.Deleted,
+		PublishedAt:  s.PublishedAt,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+		LastSeenFp:   s.LastSeenFp,
+	}
+	if s.DuplicateOf.Valid {
+		j.DuplicateOf = &s.DuplicateOf.Int64
+	}
+	return j
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/metrics"
+)
+
+// timedDBTX records the latency of every query.
+type timedDBTX struct {
+	db DBTX
+}
+
+// queryName returns the name sqlc puts in front of each query, or the
+// statement if there is none.
+func queryName(query string) string {
+	if name, ok := strings.CutPrefix(query, "-- name: "); ok {
+		name, _, _ = strings.Cut(name, " ")
+		return name
+	}
+	stmt, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToLower(stmt)
+}
+
+func observe(query string, start time.Time) {
+	metrics.ObserveDBQuery(queryName(query), time.Since(start))
+}
+
+func (t timedDBTX) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer observe(query, time.Now())
+	return t.db.ExecContext(ctx, query, args...)
+}
+
+func (t timedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.db.PrepareContext(ctx, query)
+}
+
+func (t timedDBTX) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer observe(query, time.Now())
+	return t.db.QueryContext(ctx, query, args...)
+}
+
+func (t timedDBTX) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer observe(query, time.Now())
+	return t.db.QueryRowContext(ctx, query, args...)
+}
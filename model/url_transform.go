@@ -1,15 +1,31 @@
 package model
 
 import (
+	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 )
 
-var urlTransformer = []URLTransformer{
-	// NewFarsideTransformer("twitter.com", "nitter"),
-	// NewFarsideTransformer("youtube.com", "piped"),
-	// NewFarsideTransformer("reddit.com", "libreddit"),
-	// NewFarsideTransformer("medium.com", "scribe"),
+// urlTransformers are configured, e.g. NewFarsideTransformer("twitter.com", "nitter")
+var urlTransformers = struct {
+	sync.RWMutex
+	l []URLTransformer
+}{}
+
+// SetURLTransformers replaces the transformers applied to the links of
+// stories, only the first matching one is applied.
+func SetURLTransformers(l []URLTransformer) {
+	urlTransformers.Lock()
+	defer urlTransformers.Unlock()
+	urlTransformers.l = l
+}
+
+func getURLTransformers() []URLTransformer {
+	urlTransformers.RLock()
+	defer urlTransformers.RUnlock()
+	return urlTransformers.l
 }
 
 type URLTransformer interface {
@@ -17,38 +33,127 @@ type URLTransformer interface {
 	Transform(u *url.URL) *url.URL
 }
 
+// NewFarsideTransformer redirects links to host and its subdomains to a
+// random instance of the farside service, e.g. nitter.
 func NewFarsideTransformer(host, service string) URLTransformer {
 	target, err := url.Parse("https://farside.link/" + service)
 	if err != nil {
 		panic(err)
 	}
-	return &farsideTransformer{
-		host:           strings.ToLower(host),
-		matchSubdomain: true,
-		target:         *target,
+	return &frontendTransformer{
+		hosts:  []string{strings.ToLower(host)},
+		target: *target,
 	}
 }
 
-type farsideTransformer struct {
-	host           string
-	matchSubdomain bool
-	target         url.URL
-}
-
-func (n *farsideTransformer) Matches(u *url.URL) bool {
-	host := strings.ToLower(u.Hostname())
-	if host == n.host {
-		return true
+// NewFrontendTransformer moves links to the hosts and their subdomains to
+// frontend, keeping path and query.
+func NewFrontendTransformer(frontend string, hosts ...string) (URLTransformer, error) {
+	target, err := url.Parse(frontend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontend url: %w", err)
 	}
-	if n.matchSubdomain && strings.HasSuffix(host, "."+n.host) {
-		return true
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("invalid frontend url %q, expected http or https", frontend)
 	}
-	return false
+	return &frontendTransformer{hosts: lowerAll(hosts), target: *target}, nil
+}
+
+type frontendTransformer struct {
+	hosts  []string
+	target url.URL
+}
+
+func (n *frontendTransformer) Matches(u *url.URL) bool {
+	return matchesHost(u, n.hosts)
 }
 
-func (n *farsideTransformer) Transform(u *url.URL) *url.URL {
+func (n *frontendTransformer) Transform(u *url.URL) *url.URL {
 	u.Scheme = n.target.Scheme
 	u.Host = n.target.Host
 	u.Path = strings.TrimSuffix(n.target.Path, "/") + u.Path
 	return u
 }
+
+// matchesHost reports whether u points to one of hosts or their subdomains.
+func matchesHost(u *url.URL, hosts []string) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(l []string) []string {
+	lower := make([]string, 0, len(l))
+	for _, e := range l {
+		lower = append(lower, strings.ToLower(e))
+	}
+	return lower
+}
+
+// NewRegexTransformer rewrites links matching pattern, replace may reference
+// groups as $1 or ${name}.
+func NewRegexTransformer(pattern, replace string) (URLTransformer, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return &regexTransformer{re: re, replace: replace}, nil
+}
+
+type regexTransformer struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+func (r *regexTransformer) Matches(u *url.URL) bool {
+	return r.re.MatchString(u.String())
+}
+
+func (r *regexTransformer) Transform(u *url.URL) *url.URL {
+	t, err := url.Parse(r.re.ReplaceAllString(u.String(), r.replace))
+	if err != nil {
+		return u
+	}
+	return t
+}
+
+const (
+	ArchiveOrg   = "archive.org"
+	ArchiveToday = "archive.today"
+)
+
+var archivePrefixes = map[string]string{
+	ArchiveOrg:   "https://web.archive.org/web/",
+	ArchiveToday: "https://archive.ph/newest/",
+}
+
+// NewArchiveTransformer links to the archived copy of pages on the hosts and
+// their subdomains, for paywalled sites.
+func NewArchiveTransformer(service string, hosts ...string) (URLTransformer, error) {
+	prefix, ok := archivePrefixes[service]
+	if !ok {
+		return nil, fmt.Errorf("unknown archive %q, expected %q or %q", service, ArchiveOrg, ArchiveToday)
+	}
+	return &archiveTransformer{hosts: lowerAll(hosts), prefix: prefix}, nil
+}
+
+type archiveTransformer struct {
+	hosts  []string
+	prefix string
+}
+
+func (a *archiveTransformer) Matches(u *url.URL) bool {
+	return matchesHost(u, a.hosts)
+}
+
+func (a *archiveTransformer) Transform(u *url.URL) *url.URL {
+	t, err := url.Parse(a.prefix + u.String())
+	if err != nil {
+		return u
+	}
+	return t
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// registerMutes adds the pages to manage the mute rules to the admin group.
+// The rules apply to all readers, and the browser sends the basic auth
+// credentials along with any request, so the forms carry a CSRF token.
+func registerMutes(admin *echo.Group, db *model.DB) {
+	mutes := admin.Group("/mutes", middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup:    "form:_csrf",
+		CookiePath:     "/admin/mutes",
+		CookieHTTPOnly: true,
+		CookieSameSite: http.SameSiteStrictMode,
+	}))
+
+	renderRules := func(c echo.Context, status int, errMsg string) error {
+		rules, err := db.Queries().ListMuteRules(c.Request().Context())
+		if err != nil {
+			return err
+		}
+		token, _ := c.Get(middleware.DefaultCSRFConfig.ContextKey).(string)
+		c.Response().WriteHeader(status)
+		return views.MuteRules(rules, token, errMsg).Render(c.Request().Context(), c.Response())
+	}
+
+	mutes.GET("", func(c echo.Context) error {
+		return renderRules(c, http.StatusOK, "")
+	})
+
+	mutes.POST("", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		kind := c.FormValue("kind")
+		pattern, err := model.NormalizeMuteRule(kind, c.FormValue("pattern"))
+		if err != nil {
+			return renderRules(c, http.StatusBadRequest, err.Error())
+		}
+
+		queries := db.Queries()
+		rules, err := queries.ListMuteRules(ctx)
+		if err != nil {
+			return err
+		}
+		exists := slices.ContainsFunc(rules, func(r model.MuteRule) bool {
+			return r.Kind == kind && r.Pattern == pattern
+		})
+		if !exists {
+			if _, err := queries.CreateMuteRule(ctx, model.CreateMuteRuleParams{Kind: kind, Pattern: pattern}); err != nil {
+				return err
+			}
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/mutes")
+	})
+
+	mutes.POST("/:id/delete", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+		}
+		if err := db.Queries().DeleteMuteRule(c.Request().Context(), id); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/mutes")
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+)
+
+// registerReader adds the reader view showing the extracted text of the
+// article a story links to.
+func registerReader(app *echo.Echo, db *model.DB, conf *config.Store) {
+	app.GET("/read/:id", func(c echo.Context) error {
+		story, err := getStory(c, db)
+		if err != nil {
+			return err
+		}
+		// without article the page links to the original
+		ctx := c.Request().Context()
+		article, err := db.Queries().GetArticle(ctx, story.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		s, _, err := sessionFromCookie(c, db, conf)
+		if err != nil {
+			return err
+		}
+		return views.Reader(story, article, s.RewriteLinks).Render(ctx, c.Response())
+	})
+}
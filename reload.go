@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/model"
+)
+
+// reloadOnSIGHUP calls reload whenever the process receives SIGHUP.
+func reloadOnSIGHUP(reload func() error) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		slog.Info("reloading config")
+		if err := reload(); err != nil {
+			slog.Error("could not reload config, keeping the current one", "err", err)
+			continue
+		}
+		slog.Info("config reloaded")
+	}
+}
+
+// reloadConfig applies a new config to the running app. The config is only
+// applied if it is valid and all scrapers could be created.
+func reloadConfig(load func() (config.Config, error), store *config.Store, httpc *http.Client, scheduler *job.Scheduler) error {
+	conf, err := load()
+	if err != nil {
+		return err
+	}
+	current := store.Get()
+	// the server and the DB connection are only set up on startup
+	if conf.Listen != current.Listen || conf.DBURI != current.DBURI {
+		slog.Warn("listen address and db uri are not reloaded, restart to apply them")
+		conf.Listen, conf.DBURI = current.Listen, current.DBURI
+	}
+
+	scrapers, err := loadScrapers(conf, httpc)
+	if err != nil {
+		return err
+	}
+	transformers, err := urlTransformers(conf)
+	if err != nil {
+		return err
+	}
+	model.SetURLTransformers(transformers)
+	scheduler.Reload(conf, scrapers...)
+	store.Set(conf)
+	return nil
+}
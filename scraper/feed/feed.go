@@ -0,0 +1,321 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed is the format independent representation of a parsed RSS, Atom or
+// JSON feed.
+type Feed struct {
+	Title   string
+	Link    string
+	Entries []Entry
+}
+
+type Entry struct {
+	GUID      string
+	Title     string
+	Link      string
+	Author    string
+	Published time.Time
+}
+
+// ID returns the GUID of the entry, falling back to its link for feeds
+// that don't provide one.
+func (e *Entry) ID() string {
+	if e.GUID != "" {
+		return e.GUID
+	}
+	return e.Link
+}
+
+// Parse detects the format of the feed in r and parses it.
+func Parse(r io.Reader) (Feed, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return Feed{}, err
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return Feed{}, fmt.Errorf("empty feed")
+	}
+
+	if b[0] == '{' {
+		return parseJSONFeed(b)
+	}
+
+	root, err := rootElement(b)
+	if err != nil {
+		return Feed{}, err
+	}
+	switch strings.ToLower(root) {
+	case "rss":
+		return parseRSS(b)
+	case "feed":
+		return parseAtom(b)
+	}
+	return Feed{}, fmt.Errorf("unsupported feed format, root element is %q", root)
+}
+
+func newXMLDecoder(b []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = charsetReader
+	return d
+}
+
+func rootElement(b []byte) (string, error) {
+	d := newXMLDecoder(b)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("could not find root element: %w", err)
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// charsetReader handles the non UTF-8 encodings commonly found in the wild,
+// everything in the Latin-1 range maps directly onto unicode code points.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 0, len(b))
+		for _, c := range b {
+			out = utf8.AppendRune(out, rune(c))
+		}
+		return bytes.NewReader(out), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []string  `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title   string   `xml:"title"`
+	Links   []string `xml:"link"`
+	GUID    string   `xml:"guid"`
+	PubDate string   `xml:"pubDate"`
+	Date    string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author  string   `xml:"author"`
+	Creator string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+func parseRSS(b []byte) (Feed, error) {
+	doc := rssDoc{}
+	if err := newXMLDecoder(b).Decode(&doc); err != nil {
+		return Feed{}, fmt.Errorf("could not decode RSS feed: %w", err)
+	}
+
+	f := Feed{
+		Title: cleanText(doc.Channel.Title),
+		Link:  firstNonEmpty(doc.Channel.Links...),
+	}
+	for _, itm := range doc.Channel.Items {
+		f.Entries = append(f.Entries, Entry{
+			GUID:      strings.TrimSpace(itm.GUID),
+			Title:     cleanText(itm.Title),
+			Link:      firstNonEmpty(itm.Links...),
+			Author:    cleanText(firstNonEmpty(itm.Creator, itm.Author)),
+			Published: parseTime(firstNonEmpty(itm.PubDate, itm.Date)),
+		})
+	}
+	return f, nil
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+}
+
+// alternateLink returns the first link with rel alternate, which is also
+// the default if rel is omitted.
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+func parseAtom(b []byte) (Feed, error) {
+	doc := atomFeed{}
+	if err := newXMLDecoder(b).Decode(&doc); err != nil {
+		return Feed{}, fmt.Errorf("could not decode Atom feed: %w", err)
+	}
+
+	f := Feed{
+		Title: cleanText(doc.Title),
+		Link:  alternateLink(doc.Links),
+	}
+	for _, e := range doc.Entries {
+		author := ""
+		if len(e.Authors) > 0 {
+			author = cleanText(e.Authors[0].Name)
+		}
+		f.Entries = append(f.Entries, Entry{
+			GUID:      strings.TrimSpace(e.ID),
+			Title:     cleanText(e.Title),
+			Link:      alternateLink(e.Links),
+			Author:    author,
+			Published: parseTime(firstNonEmpty(e.Published, e.Updated)),
+		})
+	}
+	return f, nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	// ID is a string since version 1.1, but 1.0 feeds may use numbers
+	ID            json.RawMessage  `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Author        *jsonFeedAuthor  `json:"author"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+}
+
+func (i *jsonFeedItem) GUID() string {
+	var s string
+	if err := json.Unmarshal(i.ID, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(string(i.ID))
+}
+
+func parseJSONFeed(b []byte) (Feed, error) {
+	doc := jsonFeed{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return Feed{}, fmt.Errorf("could not decode JSON feed: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return Feed{}, fmt.Errorf("unsupported JSON feed version %q", doc.Version)
+	}
+
+	f := Feed{
+		Title: cleanText(doc.Title),
+		Link:  doc.HomePageURL,
+	}
+	for _, itm := range doc.Items {
+		author := ""
+		switch {
+		case len(itm.Authors) > 0:
+			author = itm.Authors[0].Name
+		case itm.Author != nil:
+			author = itm.Author.Name
+		}
+		f.Entries = append(f.Entries, Entry{
+			GUID:      itm.GUID(),
+			Title:     cleanText(itm.Title),
+			Link:      firstNonEmpty(itm.URL, itm.ExternalURL),
+			Author:    cleanText(author),
+			Published: parseTime(firstNonEmpty(itm.DatePublished, itm.DateModified)),
+		})
+	}
+	return f, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime tries the date formats used by the different feed formats and
+// returns the zero time if none of them match.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, l := range timeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// cleanText strips markup from titles which some feeds embed escaped.
+func cleanText(s string) string {
+	s = tagRegexp.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
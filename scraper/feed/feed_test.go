@@ -0,0 +1,161 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Feed
+		wantErr string
+	}{
+		{
+			name: "rss",
+			input: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+  <title>LWN.net</title><link>https://lwn.net</link>
+  <item><title>Kernel &amp; friends</title><link>https://lwn.net/Articles/1/</link><guid>lwn-1</guid>
+    <pubDate>Tue, 14 May 2024 15:04:05 +0000</pubDate><dc:creator>corbet</dc:creator></item>
+</channel></rss>`,
+			want: Feed{Title: "LWN.net", Link: "https://lwn.net", Entries: []Entry{
+				{GUID: "lwn-1", Title: "Kernel & friends", Link: "https://lwn.net/Articles/1/", Author: "corbet", Published: time.Date(2024, 5, 14, 15, 4, 5, 0, time.UTC)},
+			}},
+		},
+		{
+			name: "atom",
+			input: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+  <link rel="self" href="https://example.com/feed"/><link href="https://example.com/"/>
+  <entry><id>tag:1</id><title type="html">&lt;b&gt;Hello&lt;/b&gt;</title>
+    <link rel="alternate" href="https://example.com/1"/><updated>2024-05-14T15:04:05Z</updated>
+    <author><name>Jane</name></author></entry>
+</feed>`,
+			want: Feed{Title: "Blog", Link: "https://example.com/", Entries: []Entry{
+				{GUID: "tag:1", Title: "Hello", Link: "https://example.com/1", Author: "Jane", Published: time.Date(2024, 5, 14, 15, 4, 5, 0, time.UTC)},
+			}},
+		},
+		{
+			name:  "json feed with numeric id",
+			input: `{"version":"https://jsonfeed.org/version/1","title":"JSON","home_page_url":"https://example.com","items":[{"id":42,"url":"https://example.com/42","title":"Answer","date_published":"2024-05-14T15:04:05Z","author":{"name":"Deep Thought"}}]}`,
+			want: Feed{Title: "JSON", Link: "https://example.com", Entries: []Entry{
+				{GUID: "42", Title: "Answer", Link: "https://example.com/42", Author: "Deep Thought", Published: time.Date(2024, 5, 14, 15, 4, 5, 0, time.UTC)},
+			}},
+		},
+		{
+			name:  "byte order mark and whitespace",
+			input: "\xef\xbb\xbf\n  <rss><channel><title>BOM</title></channel></rss>\n",
+			want:  Feed{Title: "BOM"},
+		},
+		{
+			name:  "latin1",
+			input: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>Caf\xe9</title></channel></rss>",
+			want:  Feed{Title: "Café"},
+		},
+		{
+			name:  "html entities and unclosed tags",
+			input: `<rss><channel><title>A&nbsp;B</title><item><title>Item<br></title><link>https://example.com/1</link></item></channel></rss>`,
+			want:  Feed{Title: "A B", Entries: []Entry{{Title: "Item", Link: "https://example.com/1"}}},
+		},
+		{
+			name:  "unparseable date",
+			input: `<rss><channel><item><guid>1</guid><pubDate>yesterday</pubDate></item></channel></rss>`,
+			want:  Feed{Entries: []Entry{{GUID: "1"}}},
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: "empty feed",
+		},
+		{
+			name:    "only whitespace and byte order mark",
+			input:   "\xef\xbb\xbf \n\t",
+			wantErr: "empty feed",
+		},
+		{
+			name:    "html page",
+			input:   `<!DOCTYPE html><html><head><title>Not a feed</title></head><body></body></html>`,
+			wantErr: `unsupported feed format, root element is "html"`,
+		},
+		{
+			name:    "plain text",
+			input:   "404 page not found",
+			wantErr: "could not find root element",
+		},
+		{
+			name:    "unsupported charset",
+			input:   `<?xml version="1.0" encoding="EBCDIC"?><rss></rss>`,
+			wantErr: `unsupported charset "EBCDIC"`,
+		},
+		{
+			name:    "truncated rss",
+			input:   `<rss><channel><title>Cut off</title><item><title>`,
+			wantErr: "could not decode RSS feed",
+		},
+		{
+			name:    "truncated atom",
+			input:   `<feed xmlns="http://www.w3.org/2005/Atom"><entry><id>1`,
+			wantErr: "could not decode Atom feed",
+		},
+		{
+			name:    "invalid json",
+			input:   `{"version":"https://jsonfeed.org/version/1.1","items":[`,
+			wantErr: "could not decode JSON feed",
+		},
+		{
+			name:    "json without version",
+			input:   `{"title":"Not a feed","items":[]}`,
+			wantErr: `unsupported JSON feed version ""`,
+		},
+		{
+			name:    "json with wrong item type",
+			input:   `{"version":"https://jsonfeed.org/version/1.1","items":{"id":"1"}}`,
+			wantErr: "could not decode JSON feed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != tt.want.Title || got.Link != tt.want.Link || len(got.Entries) != len(tt.want.Entries) {
+				t.Fatalf("got %+v\nwant %+v", got, tt.want)
+			}
+			for i, e := range got.Entries {
+				want := tt.want.Entries[i]
+				if !e.Published.Equal(want.Published) {
+					t.Errorf("entry %d published at %s, want %s", i, e.Published, want.Published)
+				}
+				e.Published = want.Published
+				if e != want {
+					t.Errorf("entry %d is %+v, want %+v", i, e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	tests := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{GUID: "guid", Link: "https://example.com/1"}, "guid"},
+		{Entry{Link: "https://example.com/1"}, "https://example.com/1"},
+		{Entry{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.ID(); got != tt.want {
+			t.Errorf("ID of %+v is %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/scraper"
+)
+
+// entries older than this are ignored, otherwise adding a feed would flood
+// the list with its whole archive
+const maxEntryAge = 24 * time.Hour
+
+// feeds larger than this are rejected
+const maxFeedSize = 10 << 20
+
+type FeedScraper struct {
+	httpc *http.Client
+	name  string
+	url   string
+
+	mu     sync.Mutex
+	latest map[string]model.Story
+}
+
+func NewScraper(httpc *http.Client, name, url string) (*FeedScraper, error) {
+	if name == "" {
+		return nil, fmt.Errorf("feed name must not be empty")
+	}
+	if url == "" {
+		return nil, fmt.Errorf("feed %q has no url", name)
+	}
+	return &FeedScraper{
+		httpc:  httpc,
+		name:   model.ScraperFeedPrefix + name,
+		url:    url,
+		latest: map[string]model.Story{},
+	}, nil
+}
+
+func (s *FeedScraper) Name() string {
+	return s.name
+}
+
+// Schedule polls feeds less often than the aggregators, most feeds are
+// updated a few times a day at most.
+func (s *FeedScraper) Schedule() scraper.Schedule {
+	return scraper.Schedule{Interval: 15 * time.Minute, Jitter: time.Minute}
+}
+
+// FetchItem serves entries from the last fetched version of the feed, as
+// feeds offer no way to look up a single entry. Entries that dropped out of
+// the feed are skipped instead of being marked deleted.
+func (s *FeedScraper) FetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	story, ok := s.latest[refId]
+	if !ok {
+		return model.Story{}, false, scraper.ErrSkipItem
+	}
+	return story, true, nil
+}
+
+func (s *FeedScraper) FetchItems(ctx context.Context) ([]model.Story, error) {
+	slog.Debug("fetching feed", "url", s.url, "scraper", s.name)
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	resp, err := s.httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
+	}
+
+	f, err := Parse(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse feed %s: %w", s.url, err)
+	}
+
+	stories := s.toStories(f, time.Now())
+
+	latest := make(map[string]model.Story, len(stories))
+	for _, story := range stories {
+		latest[story.RefID] = story
+	}
+	s.mu.Lock()
+	s.latest = latest
+	s.mu.Unlock()
+
+	return stories, nil
+}
+
+func (s *FeedScraper) toStories(f Feed, now time.Time) []model.Story {
+	entries := f.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Published.Before(entries[j].Published)
+	})
+
+	stories := []model.Story{}
+	for _, e := range entries {
+		if e.ID() == "" || e.Title == "" {
+			slog.Warn("skipping feed entry without id or title", "scraper", s.name, "entry", e)
+			continue
+		}
+		published := e.Published
+		if published.IsZero() {
+			published = now
+		}
+		if now.Sub(published) > maxEntryAge {
+			continue
+		}
+		stories = append(stories, model.Story{
+			By:          e.Author,
+			Url:         e.Link,
+			PublishedAt: published,
+			RefID:       e.ID(),
+			Title:       e.Title,
+			Type:        model.TypeFeedEntry,
+			Scraper:     s.name,
+		})
+	}
+	return stories
+}
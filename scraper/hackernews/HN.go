@@ -122,19 +122,44 @@ func (h *Hits) HasTag(s string) bool {
 }
 
 type Item struct {
-	Type     string `json:"type"`
-	Children []Item `json:"children"`
-	ObjectID int    `json:"id"`
-	Points   int    `json:"points,omitempty"`
-	StoryID  int    `json:"story_id,omitempty"`
-	Title    string `json:"title"`
-	URL      string `json:"url"`
+	Type      string    `json:"type"`
+	Children  []Item    `json:"children"`
+	ObjectID  int       `json:"id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	Points    int       `json:"points,omitempty"`
+	StoryID   int       `json:"story_id,omitempty"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
 }
 
 func (i *Item) NumComments() int {
 	return countComments(i.Children)
 }
 
+// Comments returns the comment tree below the item.
+func (i *Item) Comments() []model.Comment {
+	return toComments(i.Children)
+}
+
+func toComments(items []Item) []model.Comment {
+	comments := []model.Comment{}
+	for _, e := range items {
+		if e.Type != "comment" {
+			continue
+		}
+		comments = append(comments, model.Comment{
+			ID:        int64(e.ObjectID),
+			By:        e.Author,
+			Text:      e.Text,
+			CreatedAt: e.CreatedAt,
+			Children:  toComments(e.Children),
+		})
+	}
+	return comments
+}
+
 func countComments(i []Item) int {
 	if len(i) == 0 {
 		return 0
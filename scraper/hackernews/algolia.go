@@ -0,0 +1,108 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/floj/serializer-go/model"
+)
+
+const hnAlgoliaURL = "https://hn.algolia.com/api/v1"
+
+type algoliaBackend struct {
+	httpc    *http.Client
+	baseURL  string
+	pageSize int
+}
+
+func (b *algoliaBackend) name() string {
+	return BackendAlgolia
+}
+
+func (b *algoliaBackend) fetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
+	uri := b.baseURL + "/items/" + refId
+	slog.Debug("fetching HN story", "url", uri)
+
+	itm := Item{}
+	found, err := getJSON(ctx, b.httpc, uri, &itm)
+	if err != nil || !found {
+		return model.Story{}, found, err
+	}
+
+	return model.Story{
+		RefID:       refId,
+		Url:         itm.URL,
+		Title:       itm.Title,
+		Score:       int32(itm.Points),
+		NumComments: int32(itm.NumComments()),
+	}, true, nil
+}
+
+// fetchComments returns the comment tree of the story, the items API returns
+// it as a whole.
+func (b *algoliaBackend) fetchComments(ctx context.Context, refId string) ([]model.Comment, error) {
+	uri := b.baseURL + "/items/" + refId
+	slog.Debug("fetching HN comments", "url", uri)
+
+	itm := Item{}
+	found, err := getJSON(ctx, b.httpc, uri, &itm)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("story %s not found", refId)
+	}
+	return itm.Comments(), nil
+}
+
+func (b *algoliaBackend) fetchItems(ctx context.Context) ([]model.Story, error) {
+	uri := b.baseURL + "/search?tags=front_page&hitsPerPage=" + strconv.Itoa(b.pageSize)
+	slog.Debug("fetching HN stories", "url", uri)
+
+	searchResult := SearchResult{}
+	found, err := getJSON(ctx, b.httpc, uri, &searchResult)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("request not successful, expected status 200, got %d", http.StatusNotFound)
+	}
+
+	hits := searchResult.Hits
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].StoryID < hits[j].StoryID
+	})
+
+	stories := []model.Story{}
+
+	for _, h := range hits {
+		t := h.GetType()
+		switch t {
+		case model.TypeHNJob:
+			continue
+		case model.TypeUnknown:
+			slog.Warn("unknown type", "type", t, "scraper", "hn", "id", h.ObjectID)
+			continue
+		default:
+			story := model.Story{
+				By:          h.Author,
+				Url:         h.URL,
+				PublishedAt: h.CreatedAt,
+				RefID:       strconv.Itoa(h.StoryID),
+				Title:       h.Title,
+				Type:        t,
+				Score:       int32(h.Points),
+				Scraper:     model.ScraperHN,
+				NumComments: int32(h.NumComments),
+			}
+			stories = append(stories, story)
+		}
+	}
+
+	return stories, nil
+}
@@ -0,0 +1,163 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+const hnFirebaseURL = "https://hacker-news.firebaseio.com/v0"
+
+// max number of items fetched in parallel
+const firebaseConcurrency = 8
+
+type FirebaseItem struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type"`
+	By          string `json:"by"`
+	Time        int64  `json:"time"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Score       int    `json:"score"`
+	Descendants int    `json:"descendants"`
+	Deleted     bool   `json:"deleted"`
+	Dead        bool   `json:"dead"`
+}
+
+// GetType maps the item to the same types the Algolia API uses as tags.
+func (i *FirebaseItem) GetType() string {
+	switch i.Type {
+	case "job":
+		return model.TypeHNJob
+	case "story":
+		switch {
+		case strings.HasPrefix(i.Title, "Ask HN:"):
+			return model.TypeHNAskHN
+		case strings.HasPrefix(i.Title, "Show HN:"):
+			return model.TypeHNShowHN
+		}
+		return model.TypeHNStory
+	}
+	return model.TypeUnknown
+}
+
+type firebaseBackend struct {
+	httpc    *http.Client
+	baseURL  string
+	pageSize int
+}
+
+func (b *firebaseBackend) name() string {
+	return BackendFirebase
+}
+
+// getItem returns nil if the item doesn't exist, the API answers with null
+// instead of a 404 in that case.
+func (b *firebaseBackend) getItem(ctx context.Context, refId string) (*FirebaseItem, error) {
+	uri := b.baseURL + "/item/" + refId + ".json"
+	slog.Debug("fetching HN item", "url", uri)
+
+	var itm *FirebaseItem
+	found, err := getJSON(ctx, b.httpc, uri, &itm)
+	if err != nil || !found {
+		return nil, err
+	}
+	return itm, nil
+}
+
+func (b *firebaseBackend) fetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
+	itm, err := b.getItem(ctx, refId)
+	if err != nil {
+		return model.Story{}, false, err
+	}
+	if itm == nil || itm.Deleted || itm.Dead {
+		return model.Story{}, false, nil
+	}
+
+	return model.Story{
+		RefID:       refId,
+		Url:         itm.URL,
+		Title:       itm.Title,
+		Score:       int32(itm.Score),
+		NumComments: int32(itm.Descendants),
+	}, true, nil
+}
+
+func (b *firebaseBackend) fetchItems(ctx context.Context) ([]model.Story, error) {
+	uri := b.baseURL + "/topstories.json"
+	slog.Debug("fetching HN stories", "url", uri)
+
+	ids := []int{}
+	found, err := getJSON(ctx, b.httpc, uri, &ids)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("request not successful, expected status 200, got %d", http.StatusNotFound)
+	}
+	ids = ids[:min(len(ids), b.pageSize)]
+
+	items := make([]*FirebaseItem, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, firebaseConcurrency)
+	wg := sync.WaitGroup{}
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			items[i], errs[i] = b.getItem(ctx, strconv.Itoa(id))
+		}()
+	}
+	wg.Wait()
+
+	stories := []model.Story{}
+	for i, itm := range items {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("could not fetch item %d: %w", ids[i], errs[i])
+		}
+		if itm == nil || itm.Deleted || itm.Dead {
+			continue
+		}
+
+		t := itm.GetType()
+		switch t {
+		case model.TypeHNJob:
+			continue
+		case model.TypeUnknown:
+			slog.Warn("unknown type", "type", itm.Type, "scraper", "hn", "id", itm.ID)
+			continue
+		default:
+			stories = append(stories, model.Story{
+				By:          itm.By,
+				Url:         itm.URL,
+				PublishedAt: time.Unix(itm.Time, 0),
+				RefID:       strconv.Itoa(itm.ID),
+				Title:       itm.Title,
+				Type:        t,
+				Score:       int32(itm.Score),
+				Scraper:     model.ScraperHN,
+				NumComments: int32(itm.Descendants),
+			})
+		}
+	}
+
+	sort.Slice(stories, func(i, j int) bool {
+		a, _ := strconv.Atoi(stories[i].RefID)
+		b, _ := strconv.Atoi(stories[j].RefID)
+		return a < b
+	})
+
+	return stories, nil
+}
@@ -1,30 +1,62 @@
 package hackernews
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"sort"
-	"strconv"
 	"time"
 
 	"github.com/floj/serializer-go/model"
 )
 
-const hnSearchURL = "https://hn.algolia.com/api/v1/search?tags=front_page&hitsPerPage=30"
-const hnStoryURL = "https://hn.algolia.com/api/v1/items"
+const (
+	BackendAlgolia  = "algolia"
+	BackendFirebase = "firebase"
+)
+
+// number of stories on the HN frontpage
+const frontPageSize = 30
+
+// backend fetches HN stories from one of the available APIs, all backends
+// have to produce the same stories.
+type backend interface {
+	name() string
+	fetchItem(ctx context.Context, refId string) (model.Story, bool, error)
+	fetchItems(ctx context.Context) ([]model.Story, error)
+}
 
 type HNScraper struct {
-	httpc *http.Client
+	primary  backend
+	fallback backend
+	// comments are always fetched from algolia, the firebase API would take
+	// a request per comment
+	comments *algoliaBackend
 }
 
-func NewScraper(httpc *http.Client) (*HNScraper, error) {
-	return &HNScraper{
-		httpc: httpc,
-	}, nil
+// NewScraper creates a scraper using the given backend, the other backend is
+// used as fallback if a request to the selected one fails. pageSize is the
+// number of top stories fetched, 0 fetches the front page.
+func NewScraper(httpc *http.Client, backendName string, pageSize int) (*HNScraper, error) {
+	return newScraper(httpc, backendName, pageSize, hnAlgoliaURL, hnFirebaseURL)
+}
+
+func newScraper(httpc *http.Client, backendName string, pageSize int, algoliaURL, firebaseURL string) (*HNScraper, error) {
+	pageSize = cmp.Or(pageSize, frontPageSize)
+	algolia := &algoliaBackend{httpc: httpc, baseURL: algoliaURL, pageSize: pageSize}
+	firebase := &firebaseBackend{httpc: httpc, baseURL: firebaseURL, pageSize: pageSize}
+
+	switch backendName {
+	case "", BackendAlgolia:
+		return &HNScraper{primary: algolia, fallback: firebase, comments: algolia}, nil
+	case BackendFirebase:
+		return &HNScraper{primary: firebase, fallback: algolia, comments: algolia}, nil
+	}
+	return nil, fmt.Errorf("unknown HN backend %q, expected %q or %q", backendName, BackendAlgolia, BackendFirebase)
 }
 
 func (s *HNScraper) Name() string {
@@ -32,107 +64,67 @@ func (s *HNScraper) Name() string {
 }
 
 func (s *HNScraper) FetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
-	uri := hnStoryURL + "/" + refId
-	slog.Debug("fetching HN story", "url", uri)
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*5)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, uri, nil)
-	if err != nil {
-		return model.Story{}, false, err
+	story, found, err := s.primary.fetchItem(ctx, refId)
+	if err == nil || !s.shouldFallback(ctx) {
+		return story, found, err
 	}
-	resp, err := s.httpc.Do(req)
-	if err != nil {
-		return model.Story{}, false, err
+	slog.Warn("HN backend failed, using fallback", "backend", s.primary.name(), "fallback", s.fallback.name(), "id", refId, "err", err)
+	story, found, fallbackErr := s.fallback.fetchItem(ctx, refId)
+	if fallbackErr != nil {
+		return story, found, errors.Join(err, fallbackErr)
 	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
+	return story, found, nil
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return model.Story{}, false, nil
+func (s *HNScraper) FetchItems(ctx context.Context) ([]model.Story, error) {
+	stories, err := s.primary.fetchItems(ctx)
+	if err == nil || !s.shouldFallback(ctx) {
+		return stories, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return model.Story{}, false, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
+	slog.Warn("HN backend failed, using fallback", "backend", s.primary.name(), "fallback", s.fallback.name(), "err", err)
+	stories, fallbackErr := s.fallback.fetchItems(ctx)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fallbackErr)
 	}
+	return stories, nil
+}
 
-	itm := Item{}
-	err = json.NewDecoder(resp.Body).Decode(&itm)
-	if err != nil {
-		return model.Story{}, true, err
-	}
+func (s *HNScraper) FetchComments(ctx context.Context, refId string) ([]model.Comment, error) {
+	return s.comments.fetchComments(ctx, refId)
+}
 
-	return model.Story{
-		RefID:       refId,
-		Url:         itm.URL,
-		Title:       itm.Title,
-		Score:       int32(itm.Points),
-		NumComments: int32(itm.NumComments()),
-	}, true, nil
+// shouldFallback reports whether the fallback should be tried. It isn't if
+// the whole scrape was cancelled or ran out of time.
+func (s *HNScraper) shouldFallback(ctx context.Context) bool {
+	return s.fallback != nil && ctx.Err() == nil
 }
 
-func (s *HNScraper) FetchItems(ctx context.Context) ([]model.Story, error) {
-	uri := hnSearchURL
-	slog.Debug("fetching HN stories", "url", uri)
+// getJSON decodes the response of uri into v. It reports false without an
+// error if the server answered with 404.
+func getJSON(ctx context.Context, httpc *http.Client, uri string, v any) (bool, error) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, uri, nil)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	resp, err := s.httpc.Do(req)
+	resp, err := httpc.Do(req)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 	defer func() {
 		io.Copy(io.Discard, resp.Body)
 		resp.Body.Close()
 	}()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
-	}
-
-	searchResult := SearchResult{}
-	err = json.NewDecoder(resp.Body).Decode(&searchResult)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
 	}
 
-	hits := searchResult.Hits
-
-	sort.Slice(hits, func(i, j int) bool {
-		return hits[i].StoryID < hits[j].StoryID
-	})
-
-	stories := []model.Story{}
-
-	for _, h := range hits {
-		t := h.GetType()
-		switch t {
-		case model.TypeHNJob:
-			continue
-		case model.TypeUnknown:
-			slog.Warn("unknown type", "type", t, "scraper", "hn", "id", h.ObjectID)
-			continue
-		default:
-			story := model.Story{
-				By:          h.Author,
-				Url:         h.URL,
-				PublishedAt: h.CreatedAt,
-				RefID:       strconv.Itoa(h.StoryID),
-				Title:       h.Title,
-				Type:        t,
-				Score:       int32(h.Points),
-				Scraper:     model.ScraperHN,
-				NumComments: int32(h.NumComments),
-			}
-			stories = append(stories, story)
-		}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
 	}
 
-	return stories, nil
+	return true, json.NewDecoder(resp.Body).Decode(v)
 }
@@ -0,0 +1,371 @@
+package hackernews
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+// fixture answers with 503
+const unavailable = ""
+
+// fixture that doesn't answer until the client gives up
+const stalled = "stalled"
+
+// newTestServer answers requests with the given fixtures from testdata, keyed
+// by path. Other paths get 404. The returned counter holds the number of
+// requests served.
+func newTestServer(t *testing.T, fixtures map[string]string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		name, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch name {
+		case unavailable:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		case stalled:
+			<-r.Context().Done()
+			return
+		}
+		b, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Errorf("could not read fixture: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+var firebaseFixtures = map[string]string{
+	"/topstories.json":    "topstories.json",
+	"/item/40370100.json": "item_40370100.json",
+	"/item/40370200.json": "item_40370200.json",
+	"/item/40370300.json": "item_40370300.json",
+	"/item/40370400.json": "item_40370400.json",
+	"/item/40370500.json": "item_40370500.json",
+}
+
+var algoliaFixtures = map[string]string{
+	"/search":         "front_page.json",
+	"/items/40370100": "algolia_item_40370100.json",
+}
+
+// with overrides the fixtures of base, e.g. to let a path fail
+func with(base map[string]string, overrides map[string]string) map[string]string {
+	fixtures := map[string]string{}
+	for k, v := range base {
+		fixtures[k] = v
+	}
+	for k, v := range overrides {
+		fixtures[k] = v
+	}
+	return fixtures
+}
+
+type testServers struct {
+	scraper          *HNScraper
+	algoliaRequests  *atomic.Int32
+	firebaseRequests *atomic.Int32
+}
+
+func newTestScraper(t *testing.T, backendName string, pageSize int, algolia, firebase map[string]string) testServers {
+	t.Helper()
+	algoliaSrv, algoliaRequests := newTestServer(t, algolia)
+	firebaseSrv, firebaseRequests := newTestServer(t, firebase)
+
+	// short enough to not slow down the tests with stalled fixtures
+	httpc := &http.Client{Timeout: 200 * time.Millisecond}
+	s, err := newScraper(httpc, backendName, pageSize, algoliaSrv.URL, firebaseSrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testServers{scraper: s, algoliaRequests: algoliaRequests, firebaseRequests: firebaseRequests}
+}
+
+func refIDs(stories []model.Story) []string {
+	refs := []string{}
+	for _, s := range stories {
+		refs = append(refs, s.RefID)
+	}
+	return refs
+}
+
+func TestFirebaseFetchItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageSize int
+		fixtures map[string]string
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			// the job, the dead story and the missing item are left out
+			name:     "front page",
+			fixtures: firebaseFixtures,
+			wantRefs: []string{"40370100", "40370300"},
+		},
+		{
+			name:     "page size",
+			pageSize: 2,
+			fixtures: firebaseFixtures,
+			wantRefs: []string{"40370100"},
+		},
+		{
+			name:     "top stories unavailable",
+			fixtures: with(firebaseFixtures, map[string]string{"/topstories.json": unavailable}),
+			wantErr:  true,
+		},
+		{
+			name:     "top stories not found",
+			fixtures: map[string]string{},
+			wantErr:  true,
+		},
+		{
+			name:     "item unavailable",
+			fixtures: with(firebaseFixtures, map[string]string{"/item/40370300.json": unavailable}),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// algolia fails as well, so the stories can only come from firebase
+			ts := newTestScraper(t, BackendFirebase, tt.pageSize, map[string]string{}, tt.fixtures)
+			stories, err := ts.scraper.FetchItems(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d stories", len(stories))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refs := refIDs(stories); strings.Join(refs, ",") != strings.Join(tt.wantRefs, ",") {
+				t.Fatalf("got stories %v, want %v, oldest first", refs, tt.wantRefs)
+			}
+			if n := ts.algoliaRequests.Load(); n != 0 {
+				t.Errorf("got %d requests to algolia, want none", n)
+			}
+		})
+	}
+}
+
+// TestBackendsConvertStories checks that both backends produce the same
+// stories from the same front page.
+func TestBackendsConvertStories(t *testing.T) {
+	want := []model.Story{
+		{
+			RefID:       "40370100",
+			Url:         "https://github.com/example/sqlite-tui",
+			By:          "builder",
+			Title:       "Show HN: A tiny TUI for SQLite",
+			Type:        model.TypeHNShowHN,
+			Score:       187,
+			NumComments: 42,
+			Scraper:     model.ScraperHN,
+			PublishedAt: time.Unix(1715700000, 0),
+		},
+		{
+			RefID:       "40370300",
+			By:          "curious",
+			Title:       "Ask HN: How do you review large refactorings?",
+			Type:        model.TypeHNAskHN,
+			Score:       95,
+			NumComments: 88,
+			Scraper:     model.ScraperHN,
+			PublishedAt: time.Unix(1715695000, 0),
+		},
+	}
+	for _, backendName := range []string{BackendAlgolia, BackendFirebase} {
+		t.Run(backendName, func(t *testing.T) {
+			ts := newTestScraper(t, backendName, 0, algoliaFixtures, firebaseFixtures)
+			stories, err := ts.scraper.FetchItems(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stories) != len(want) {
+				t.Fatalf("got stories %v, want %d", refIDs(stories), len(want))
+			}
+			for i, got := range stories {
+				if !got.PublishedAt.Equal(want[i].PublishedAt) {
+					t.Errorf("%s: published at %s, want %s", got.RefID, got.PublishedAt, want[i].PublishedAt)
+				}
+				got.PublishedAt = want[i].PublishedAt
+				if got != want[i] {
+					t.Errorf("got %+v\nwant %+v", got, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFirebaseFetchItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		fixtures  map[string]string
+		wantFound bool
+		wantErr   bool
+	}{
+		{
+			name:      "found",
+			ref:       "40370100",
+			fixtures:  firebaseFixtures,
+			wantFound: true,
+		},
+		{
+			name:     "dead",
+			ref:      "40370400",
+			fixtures: firebaseFixtures,
+		},
+		{
+			// the API answers with null for unknown items
+			name:     "null",
+			ref:      "40370500",
+			fixtures: firebaseFixtures,
+		},
+		{
+			name:     "not found",
+			ref:      "40370999",
+			fixtures: firebaseFixtures,
+		},
+		{
+			name:     "server error",
+			ref:      "40370100",
+			fixtures: with(firebaseFixtures, map[string]string{"/item/40370100.json": unavailable}),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestScraper(t, BackendFirebase, 0, map[string]string{"/items/" + tt.ref: unavailable}, tt.fixtures)
+			story, found, err := ts.scraper.FetchItem(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("got found %t, want %t", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			want := model.Story{
+				RefID:       "40370100",
+				Url:         "https://github.com/example/sqlite-tui",
+				Title:       "Show HN: A tiny TUI for SQLite",
+				Score:       187,
+				NumComments: 42,
+			}
+			if story != want {
+				t.Errorf("got %+v\nwant %+v", story, want)
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		primary  string
+		algolia  map[string]string
+		firebase map[string]string
+		// the backend the stories are expected from, their scores differ
+		wantScore int32
+		wantErr   bool
+	}{
+		{
+			name:      "firebase unavailable",
+			primary:   BackendFirebase,
+			algolia:   algoliaFixtures,
+			firebase:  with(firebaseFixtures, map[string]string{"/topstories.json": unavailable, "/item/40370100.json": unavailable}),
+			wantScore: 190,
+		},
+		{
+			name:      "firebase stalled",
+			primary:   BackendFirebase,
+			algolia:   algoliaFixtures,
+			firebase:  with(firebaseFixtures, map[string]string{"/topstories.json": stalled, "/item/40370100.json": stalled}),
+			wantScore: 190,
+		},
+		{
+			name:      "algolia unavailable",
+			primary:   BackendAlgolia,
+			algolia:   with(algoliaFixtures, map[string]string{"/search": unavailable, "/items/40370100": unavailable}),
+			firebase:  firebaseFixtures,
+			wantScore: 187,
+		},
+		{
+			name:     "both unavailable",
+			primary:  BackendFirebase,
+			algolia:  with(algoliaFixtures, map[string]string{"/search": unavailable, "/items/40370100": unavailable}),
+			firebase: with(firebaseFixtures, map[string]string{"/topstories.json": unavailable, "/item/40370100.json": unavailable}),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestScraper(t, tt.primary, 0, tt.algolia, tt.firebase)
+
+			stories, err := ts.scraper.FetchItems(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d stories", len(stories))
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if refs := refIDs(stories); strings.Join(refs, ",") != "40370100,40370300" {
+					t.Fatalf("got stories %v", refs)
+				}
+			}
+
+			story, found, err := ts.scraper.FetchItem(context.Background(), "40370100")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", story)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !found || story.Score != tt.wantScore {
+				t.Errorf("got found %t, story %+v, want score %d", found, story, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestNoFallbackWhenCancelled(t *testing.T) {
+	ts := newTestScraper(t, BackendFirebase, 0, algoliaFixtures, map[string]string{"/topstories.json": stalled})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ts.scraper.FetchItems(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := ts.algoliaRequests.Load(); n != 0 {
+		t.Errorf("got %d requests to algolia, want none", n)
+	}
+	if n := ts.firebaseRequests.Load(); n != 1 {
+		t.Errorf("got %d requests to firebase, want 1", n)
+	}
+}
@@ -0,0 +1,71 @@
+package lobsters
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+type Story struct {
+	ShortID       string    `json:"short_id"`
+	ShortIDURL    string    `json:"short_id_url"`
+	CreatedAt     time.Time `json:"created_at"`
+	Title         string    `json:"title"`
+	URL           string    `json:"url"`
+	Score         int       `json:"score"`
+	Flags         int       `json:"flags"`
+	CommentCount  int       `json:"comment_count"`
+	Description   string    `json:"description"`
+	CommentsURL   string    `json:"comments_url"`
+	SubmitterUser User      `json:"submitter_user"`
+	Tags          []string  `json:"tags"`
+}
+
+// User is the submitter of a story. Older versions of the API return an
+// object, newer ones just the username.
+type User struct {
+	Username string `json:"username"`
+}
+
+func (u *User) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &u.Username)
+	}
+	type user User
+	return json.Unmarshal(b, (*user)(u))
+}
+
+func (s *Story) GetType() string {
+	switch {
+	case s.HasTag("ask"):
+		return model.TypeLobstersAsk
+	case s.HasTag("show"):
+		return model.TypeLobstersShow
+	}
+	return model.TypeLobstersStory
+}
+
+func (s *Story) HasTag(t string) bool {
+	for _, tag := range s.Tags {
+		if strings.EqualFold(tag, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Story) ToStory() model.Story {
+	return model.Story{
+		By:          s.SubmitterUser.Username,
+		Url:         s.URL,
+		PublishedAt: s.CreatedAt,
+		RefID:       s.ShortID,
+		Title:       s.Title,
+		Type:        s.GetType(),
+		Score:       int32(s.Score),
+		Scraper:     model.ScraperLobsters,
+		NumComments: int32(s.CommentCount),
+	}
+}
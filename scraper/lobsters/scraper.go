@@ -0,0 +1,117 @@
+package lobsters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+const lobstersBaseURL = "https://lobste.rs"
+
+const (
+	ListingHottest = "hottest"
+	ListingNewest  = "newest"
+)
+
+type LobstersScraper struct {
+	httpc   *http.Client
+	baseURL string
+	listing string
+}
+
+func NewScraper(httpc *http.Client, listing string) (*LobstersScraper, error) {
+	switch listing {
+	case "":
+		listing = ListingHottest
+	case ListingHottest, ListingNewest:
+	default:
+		return nil, fmt.Errorf("unknown lobsters listing %q, expected %q or %q", listing, ListingHottest, ListingNewest)
+	}
+	return &LobstersScraper{
+		httpc:   httpc,
+		baseURL: lobstersBaseURL,
+		listing: listing,
+	}, nil
+}
+
+func (s *LobstersScraper) Name() string {
+	return model.ScraperLobsters
+}
+
+func (s *LobstersScraper) FetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
+	uri := s.baseURL + "/s/" + url.PathEscape(refId) + ".json"
+	slog.Debug("fetching lobsters story", "url", uri)
+
+	itm := Story{}
+	found, err := s.getJSON(ctx, uri, &itm)
+	if err != nil || !found {
+		return model.Story{}, found, err
+	}
+
+	story := itm.ToStory()
+	story.RefID = refId
+	return story, true, nil
+}
+
+func (s *LobstersScraper) FetchItems(ctx context.Context) ([]model.Story, error) {
+	uri := s.baseURL + "/" + s.listing + ".json"
+	slog.Debug("fetching lobsters stories", "url", uri)
+
+	items := []Story{}
+	found, err := s.getJSON(ctx, uri, &items)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("request not successful, expected status 200, got %d", http.StatusNotFound)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	stories := make([]model.Story, 0, len(items))
+	for _, itm := range items {
+		stories = append(stories, itm.ToStory())
+	}
+	return stories, nil
+}
+
+// getJSON decodes the response of uri into v. It reports false without an
+// error if the server answered with 404.
+func (s *LobstersScraper) getJSON(ctx context.Context, uri string, v any) (bool, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, uri, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.httpc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
+	}
+
+	return true, json.NewDecoder(resp.Body).Decode(v)
+}
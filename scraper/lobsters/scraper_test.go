@@ -0,0 +1,236 @@
+package lobsters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+// newTestScraper returns a scraper whose requests are answered by the given
+// fixtures from testdata, keyed by path. Other paths get 404.
+func newTestScraper(t *testing.T, listing string, fixtures map[string]string) *LobstersScraper {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if name == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		b, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Errorf("could not read fixture: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := NewScraper(srv.Client(), listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.baseURL = srv.URL
+	return s
+}
+
+func TestFetchItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		listing  string
+		fixtures map[string]string
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			name:     "hottest",
+			listing:  ListingHottest,
+			fixtures: map[string]string{"/hottest.json": "hottest.json"},
+			wantRefs: []string{"a1b2c3", "wq3jxs", "zz9y8x"},
+		},
+		{
+			name:     "newest",
+			listing:  ListingNewest,
+			fixtures: map[string]string{"/newest.json": "hottest.json"},
+			wantRefs: []string{"a1b2c3", "wq3jxs", "zz9y8x"},
+		},
+		{
+			name:     "not found",
+			listing:  ListingHottest,
+			fixtures: map[string]string{},
+			wantErr:  true,
+		},
+		{
+			name:     "server error",
+			listing:  ListingHottest,
+			fixtures: map[string]string{"/hottest.json": ""},
+			wantErr:  true,
+		},
+		{
+			name:     "malformed json",
+			listing:  ListingHottest,
+			fixtures: map[string]string{"/hottest.json": "story.json"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(t, tt.listing, tt.fixtures)
+			stories, err := s.FetchItems(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d stories", len(stories))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			refs := []string{}
+			for _, st := range stories {
+				refs = append(refs, st.RefID)
+			}
+			if len(refs) != len(tt.wantRefs) {
+				t.Fatalf("got stories %v, want %v", refs, tt.wantRefs)
+			}
+			for i := range refs {
+				if refs[i] != tt.wantRefs[i] {
+					t.Fatalf("got stories %v, want %v, oldest first", refs, tt.wantRefs)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchItemsConvertsStories(t *testing.T) {
+	s := newTestScraper(t, ListingHottest, map[string]string{"/hottest.json": "hottest.json"})
+	stories, err := s.FetchItems(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byRef := map[string]model.Story{}
+	for _, st := range stories {
+		byRef[st.RefID] = st
+	}
+
+	tests := []struct {
+		ref  string
+		want model.Story
+	}{
+		{
+			ref: "wq3jxs",
+			want: model.Story{
+				RefID:       "wq3jxs",
+				Url:         "https://go.dev/blog/loopvar-preview",
+				By:          "gopher",
+				Title:       "Go 1.22.3 and the loop variable change in practice",
+				Type:        model.TypeLobstersStory,
+				Score:       42,
+				NumComments: 17,
+				Scraper:     model.ScraperLobsters,
+				PublishedAt: time.Date(2024, 5, 14, 14, 41, 7, 0, time.UTC),
+			},
+		},
+		{
+			ref: "a1b2c3",
+			want: model.Story{
+				RefID:       "a1b2c3",
+				By:          "reviewer",
+				Title:       "Ask: How do you review large refactorings?",
+				Type:        model.TypeLobstersAsk,
+				Score:       18,
+				NumComments: 31,
+				Scraper:     model.ScraperLobsters,
+				PublishedAt: time.Date(2024, 5, 14, 12, 2, 55, 0, time.UTC),
+			},
+		},
+		{
+			// older API versions return the submitter as an object
+			ref: "zz9y8x",
+			want: model.Story{
+				RefID:       "zz9y8x",
+				Url:         "https://github.com/example/sqlite-tui",
+				By:          "builder",
+				Title:       "Show Lobsters: a tiny TUI for SQLite",
+				Type:        model.TypeLobstersShow,
+				Score:       9,
+				NumComments: 2,
+				Scraper:     model.ScraperLobsters,
+				PublishedAt: time.Date(2024, 5, 14, 16, 20, 0, 0, time.UTC),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, ok := byRef[tt.ref]
+			if !ok {
+				t.Fatalf("story %s not found", tt.ref)
+			}
+			if !got.PublishedAt.Equal(tt.want.PublishedAt) {
+				t.Errorf("published at %s, want %s", got.PublishedAt, tt.want.PublishedAt)
+			}
+			got.PublishedAt = tt.want.PublishedAt
+			if got != tt.want {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		fixtures  map[string]string
+		wantFound bool
+		wantScore int32
+		wantErr   bool
+	}{
+		{
+			name:      "found",
+			ref:       "wq3jxs",
+			fixtures:  map[string]string{"/s/wq3jxs.json": "story.json"},
+			wantFound: true,
+			wantScore: 57,
+		},
+		{
+			name:     "deleted",
+			ref:      "gone12",
+			fixtures: map[string]string{},
+		},
+		{
+			name:     "server error",
+			ref:      "wq3jxs",
+			fixtures: map[string]string{"/s/wq3jxs.json": ""},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(t, ListingHottest, tt.fixtures)
+			story, found, err := s.FetchItem(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("got found %t, want %t", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			if story.RefID != tt.ref || story.Score != tt.wantScore || story.NumComments != 23 {
+				t.Errorf("got %+v", story)
+			}
+		})
+	}
+}
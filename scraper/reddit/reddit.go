@@ -0,0 +1,73 @@
+package reddit
+
+import (
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+type Listing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Children []Thing `json:"children"`
+	} `json:"data"`
+}
+
+type Thing struct {
+	Kind string `json:"kind"`
+	Data Post   `json:"data"`
+}
+
+type Post struct {
+	ID                string  `json:"id"`
+	Name              string  `json:"name"`
+	Subreddit         string  `json:"subreddit"`
+	Title             string  `json:"title"`
+	URL               string  `json:"url"`
+	Permalink         string  `json:"permalink"`
+	Author            string  `json:"author"`
+	CreatedUTC        float64 `json:"created_utc"`
+	Score             int     `json:"score"`
+	NumComments       int     `json:"num_comments"`
+	IsSelf            bool    `json:"is_self"`
+	Stickied          bool    `json:"stickied"`
+	Selftext          string  `json:"selftext"`
+	RemovedByCategory *string `json:"removed_by_category"`
+}
+
+func (p *Post) GetType() string {
+	if p.IsSelf {
+		return model.TypeRedditSelf
+	}
+	return model.TypeRedditLink
+}
+
+// IsRemoved reports whether the post was removed by the moderators or
+// deleted by its author.
+func (p *Post) IsRemoved() bool {
+	if p.RemovedByCategory != nil {
+		return true
+	}
+	if p.Author == "[deleted]" {
+		return true
+	}
+	return p.IsSelf && (p.Selftext == "[removed]" || p.Selftext == "[deleted]")
+}
+
+func (p *Post) ToStory() model.Story {
+	u := p.URL
+	if p.IsSelf {
+		u = ""
+	}
+	return model.Story{
+		By:          p.Author,
+		Url:         u,
+		PublishedAt: time.Unix(int64(p.CreatedUTC), 0),
+		RefID:       p.ID,
+		Title:       p.Title,
+		Type:        p.GetType(),
+		Score:       int32(p.Score),
+		Scraper:     model.ScraperReddit,
+		NumComments: int32(p.NumComments),
+	}
+}
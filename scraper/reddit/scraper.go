@@ -0,0 +1,157 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/scraper"
+)
+
+const redditBaseURL = "https://www.reddit.com"
+
+// reddit rejects requests with the default user agent of the http client
+const userAgent = "serializer-go/1.0 (+https://github.com/floj/serializer-go)"
+
+type Subreddit struct {
+	Name     string
+	MinScore int
+}
+
+type RedditScraper struct {
+	httpc      *http.Client
+	baseURL    string
+	subreddits []Subreddit
+}
+
+func NewScraper(httpc *http.Client, subreddits ...Subreddit) (*RedditScraper, error) {
+	if len(subreddits) == 0 {
+		return nil, fmt.Errorf("no subreddits configured")
+	}
+	return &RedditScraper{
+		httpc:      httpc,
+		baseURL:    redditBaseURL,
+		subreddits: subreddits,
+	}, nil
+}
+
+func (s *RedditScraper) Name() string {
+	return model.ScraperReddit
+}
+
+// Schedule keeps well below the rate limit reddit applies to clients without
+// OAuth.
+func (s *RedditScraper) Schedule() scraper.Schedule {
+	return scraper.Schedule{Interval: 5 * time.Minute, Jitter: 30 * time.Second}
+}
+
+// FetchItem reports removed and deleted posts as not found, so they get
+// marked as deleted.
+func (s *RedditScraper) FetchItem(ctx context.Context, refId string) (model.Story, bool, error) {
+	uri := s.baseURL + "/by_id/t3_" + url.PathEscape(refId) + ".json?raw_json=1"
+	slog.Debug("fetching reddit post", "url", uri)
+
+	listing := Listing{}
+	found, err := s.getJSON(ctx, uri, &listing)
+	if err != nil || !found {
+		return model.Story{}, found, err
+	}
+
+	for _, t := range listing.Data.Children {
+		if t.Data.ID != refId {
+			continue
+		}
+		if t.Data.IsRemoved() {
+			return model.Story{}, false, nil
+		}
+		return t.Data.ToStory(), true, nil
+	}
+	return model.Story{}, false, nil
+}
+
+func (s *RedditScraper) FetchItems(ctx context.Context) ([]model.Story, error) {
+	posts := []Post{}
+	for _, sub := range s.subreddits {
+		p, err := s.fetchSubreddit(ctx, sub)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch r/%s: %w", sub.Name, err)
+		}
+		posts = append(posts, p...)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].CreatedUTC < posts[j].CreatedUTC
+	})
+
+	stories := make([]model.Story, 0, len(posts))
+	for _, p := range posts {
+		stories = append(stories, p.ToStory())
+	}
+	return stories, nil
+}
+
+func (s *RedditScraper) fetchSubreddit(ctx context.Context, sub Subreddit) ([]Post, error) {
+	uri := s.baseURL + "/r/" + url.PathEscape(sub.Name) + "/hot.json?limit=50&raw_json=1"
+	slog.Debug("fetching reddit posts", "url", uri)
+
+	listing := Listing{}
+	found, err := s.getJSON(ctx, uri, &listing)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("subreddit does not exist")
+	}
+
+	posts := []Post{}
+	for _, t := range listing.Data.Children {
+		p := t.Data
+		if t.Kind != "t3" || p.Stickied || p.IsRemoved() {
+			continue
+		}
+		if p.Score < sub.MinScore {
+			continue
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// getJSON decodes the response of uri into v. It reports false without an
+// error if the server answered with 404.
+func (s *RedditScraper) getJSON(ctx context.Context, uri string, v any) (bool, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, uri, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.httpc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("request not successful, expected status 200, got %d", resp.StatusCode)
+	}
+
+	return true, json.NewDecoder(resp.Body).Decode(v)
+}
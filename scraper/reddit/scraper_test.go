@@ -0,0 +1,244 @@
+package reddit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+// newTestScraper returns a scraper whose requests are answered by the given
+// fixtures from testdata, keyed by path. An empty fixture answers with 503,
+// other paths get 404.
+func newTestScraper(t *testing.T, fixtures map[string]string, subreddits ...Subreddit) *RedditScraper {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		name, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if name == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		b, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Errorf("could not read fixture: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := NewScraper(srv.Client(), subreddits...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.baseURL = srv.URL
+	return s
+}
+
+var hotFixtures = map[string]string{
+	"/r/golang/hot.json":      "golang_hot.json",
+	"/r/programming/hot.json": "programming_hot.json",
+}
+
+func TestFetchItems(t *testing.T) {
+	tests := []struct {
+		name       string
+		subreddits []Subreddit
+		fixtures   map[string]string
+		// oldest first
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			name:       "stickied, removed and deleted posts are skipped",
+			subreddits: []Subreddit{{Name: "golang"}},
+			fixtures:   hotFixtures,
+			wantRefs:   []string{"1crd4e5", "1crfa2b"},
+		},
+		{
+			name:       "min score",
+			subreddits: []Subreddit{{Name: "golang", MinScore: 20}},
+			fixtures:   hotFixtures,
+			wantRefs:   []string{"1crfa2b"},
+		},
+		{
+			name:       "min score includes the limit",
+			subreddits: []Subreddit{{Name: "golang", MinScore: 12}},
+			fixtures:   hotFixtures,
+			wantRefs:   []string{"1crd4e5", "1crfa2b"},
+		},
+		{
+			name:       "several subreddits merged by time",
+			subreddits: []Subreddit{{Name: "golang", MinScore: 20}, {Name: "programming", MinScore: 100}},
+			fixtures:   hotFixtures,
+			wantRefs:   []string{"1crp1q2", "1crfa2b"},
+		},
+		{
+			name:       "unknown subreddit",
+			subreddits: []Subreddit{{Name: "golang"}, {Name: "doesnotexist"}},
+			fixtures:   hotFixtures,
+			wantErr:    true,
+		},
+		{
+			name:       "server error",
+			subreddits: []Subreddit{{Name: "golang"}},
+			fixtures:   map[string]string{"/r/golang/hot.json": ""},
+			wantErr:    true,
+		},
+		{
+			name:       "malformed json",
+			subreddits: []Subreddit{{Name: "golang"}},
+			fixtures:   map[string]string{"/r/golang/hot.json": "truncated.json"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(t, tt.fixtures, tt.subreddits...)
+			stories, err := s.FetchItems(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d stories", len(stories))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			refs := []string{}
+			for _, st := range stories {
+				refs = append(refs, st.RefID)
+			}
+			if !slices.Equal(refs, tt.wantRefs) {
+				t.Errorf("got stories %v, want %v", refs, tt.wantRefs)
+			}
+		})
+	}
+}
+
+func TestFetchItemsConvertsStories(t *testing.T) {
+	s := newTestScraper(t, hotFixtures, Subreddit{Name: "golang"})
+	stories, err := s.FetchItems(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Story{
+		{
+			RefID:       "1crd4e5",
+			By:          "curious_dev",
+			Title:       "Channels or mutex for a small cache?",
+			Type:        model.TypeRedditSelf,
+			Score:       12,
+			NumComments: 19,
+			Scraper:     model.ScraperReddit,
+			PublishedAt: time.Unix(1715690000, 0),
+		},
+		{
+			RefID:       "1crfa2b",
+			Url:         "https://go.dev/doc/devel/release#go1.22.3",
+			By:          "gopher_news",
+			Title:       "Go 1.22.3 is released",
+			Type:        model.TypeRedditLink,
+			Score:       250,
+			NumComments: 37,
+			Scraper:     model.ScraperReddit,
+			PublishedAt: time.Unix(1715700000, 0),
+		},
+	}
+	if len(stories) != len(want) {
+		t.Fatalf("got %d stories, want %d", len(stories), len(want))
+	}
+	for i, got := range stories {
+		if !got.PublishedAt.Equal(want[i].PublishedAt) {
+			t.Errorf("story %s published at %s, want %s", got.RefID, got.PublishedAt, want[i].PublishedAt)
+		}
+		got.PublishedAt = want[i].PublishedAt
+		if got != want[i] {
+			t.Errorf("got %+v\nwant %+v", got, want[i])
+		}
+	}
+}
+
+func TestFetchItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		fixtures  map[string]string
+		wantFound bool
+		wantScore int32
+		wantErr   bool
+	}{
+		{
+			name:      "found",
+			ref:       "1crfa2b",
+			fixtures:  map[string]string{"/by_id/t3_1crfa2b.json": "by_id.json"},
+			wantFound: true,
+			wantScore: 312,
+		},
+		{
+			name:     "removed by the moderators",
+			ref:      "1crg7h8",
+			fixtures: map[string]string{"/by_id/t3_1crg7h8.json": "by_id_removed.json"},
+		},
+		{
+			name:     "deleted by the author",
+			ref:      "1crd4e5",
+			fixtures: map[string]string{"/by_id/t3_1crd4e5.json": "by_id_deleted.json"},
+		},
+		{
+			name:     "other post returned",
+			ref:      "1crd4e5",
+			fixtures: map[string]string{"/by_id/t3_1crd4e5.json": "by_id.json"},
+		},
+		{
+			name:     "not found",
+			ref:      "1crzzzz",
+			fixtures: map[string]string{},
+		},
+		{
+			name:     "server error",
+			ref:      "1crfa2b",
+			fixtures: map[string]string{"/by_id/t3_1crfa2b.json": ""},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(t, tt.fixtures, Subreddit{Name: "golang"})
+			story, found, err := s.FetchItem(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("got found %t, want %t", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			if story.RefID != tt.ref || story.Score != tt.wantScore || story.NumComments != 54 || story.Deleted {
+				t.Errorf("got %+v", story)
+			}
+		})
+	}
+}
+
+func TestNewScraperWithoutSubreddits(t *testing.T) {
+	if _, err := NewScraper(http.DefaultClient); err == nil {
+		t.Error("expected an error without subreddits")
+	}
+}
@@ -0,0 +1,208 @@
+package resilient
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// BreakerStatus is the state of the circuit breaker of a host.
+type BreakerStatus struct {
+	Host                string     `json:"host"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+}
+
+// breakers holds the breaker of each host. Breakers of hosts that were not
+// requested for a while are dropped, and at most maxHosts are kept, so
+// requests to arbitrary hosts don't grow it without bound.
+type breakers struct {
+	idleTimeout time.Duration
+	maxHosts    int
+
+	mu        sync.Mutex
+	m         map[string]*breaker
+	lastUsed  map[string]time.Time
+	lastPrune time.Time
+}
+
+func newBreakers(opts Options) *breakers {
+	return &breakers{
+		idleTimeout: opts.IdleTimeout,
+		maxHosts:    opts.MaxHosts,
+		m:           map[string]*breaker{},
+		lastUsed:    map[string]time.Time{},
+	}
+}
+
+func (b *breakers) get(host string, now time.Time) *breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br, ok := b.m[host]
+	if !ok {
+		if len(b.m) >= b.maxHosts || now.Sub(b.lastPrune) >= b.idleTimeout {
+			b.prune(now)
+		}
+		br = &breaker{host: host, state: StateClosed}
+		b.m[host] = br
+	}
+	b.lastUsed[host] = now
+	return br
+}
+
+// prune drops the breakers of idle hosts. If there are still too many, the
+// least recently used one is dropped to make room for a new host.
+func (b *breakers) prune(now time.Time) {
+	b.lastPrune = now
+	for host, br := range b.m {
+		if now.Sub(b.lastUsed[host]) >= b.idleTimeout && br.idle(now) {
+			delete(b.m, host)
+			delete(b.lastUsed, host)
+		}
+	}
+	for len(b.m) >= b.maxHosts {
+		oldest := ""
+		for host := range b.m {
+			if oldest == "" || b.lastUsed[host].Before(b.lastUsed[oldest]) {
+				oldest = host
+			}
+		}
+		delete(b.m, oldest)
+		delete(b.lastUsed, oldest)
+	}
+}
+
+func (b *breakers) status(now time.Time) []BreakerStatus {
+	b.mu.Lock()
+	all := make([]*breaker, 0, len(b.m))
+	for _, br := range b.m {
+		all = append(all, br)
+	}
+	b.mu.Unlock()
+
+	status := make([]BreakerStatus, 0, len(all))
+	for _, br := range all {
+		status = append(status, br.status(now))
+	}
+	slices.SortFunc(status, func(a, b BreakerStatus) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+	return status
+}
+
+// breaker is closed while the host works. It opens after too many consecutive
+// failures and rejects all requests until openUntil. Then it is half-open and
+// lets a single probe request through, which closes or opens it again.
+type breaker struct {
+	mu            sync.Mutex
+	host          string
+	state         string
+	failures      int
+	lastError     string
+	lastFailureAt time.Time
+	openUntil     time.Time
+	probing       bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state = StateHalfOpen
+		fallthrough
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// idle reports whether dropping the breaker loses nothing but its history:
+// it doesn't keep the host blocked and no probe is running.
+func (b *breaker) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.probing && (b.state != StateOpen || !now.Before(b.openUntil))
+}
+
+// release gives up a probe without a result.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateClosed {
+		slog.Info("circuit breaker closed", "host", b.host)
+	}
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure(now time.Time, reason string, retryAfter time.Duration, opts Options) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastError = reason
+	b.lastFailureAt = now
+	b.probing = false
+
+	tripped := b.state == StateHalfOpen || b.failures >= opts.FailureThreshold
+	if !tripped && retryAfter == 0 {
+		return
+	}
+	// a server asking to wait longer than we retry is left alone until then
+	b.openUntil = now.Add(retryAfter)
+	if tripped {
+		b.openUntil = now.Add(max(opts.OpenTimeout, retryAfter))
+	}
+	if b.state != StateOpen {
+		slog.Warn("circuit breaker opened", "host", b.host, "failures", b.failures, "until", b.openUntil, "err", reason)
+	}
+	b.state = StateOpen
+}
+
+func (b *breaker) status(now time.Time) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStatus{
+		Host:                b.host,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if !b.lastFailureAt.IsZero() {
+		t := b.lastFailureAt
+		s.LastFailureAt = &t
+	}
+	if b.state == StateOpen {
+		t := b.openUntil
+		s.OpenUntil = &t
+		if !now.Before(t) {
+			// the next request probes the host
+			s.State = StateHalfOpen
+		}
+	}
+	return s
+}
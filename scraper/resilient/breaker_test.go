@@ -0,0 +1,217 @@
+package resilient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	opts := Options{FailureThreshold: 2, OpenTimeout: time.Minute}.withDefaults()
+
+	type step struct {
+		// at is the time of the step relative to the start
+		at time.Duration
+		// op is allow, release, success or failure
+		op         string
+		retryAfter time.Duration
+		// wantAllow is checked for allow steps
+		wantAllow bool
+		wantState string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below threshold",
+			steps: []step{
+				{op: "allow", wantAllow: true, wantState: StateClosed},
+				{op: "failure", wantState: StateClosed},
+				{op: "success", wantState: StateClosed},
+				{op: "failure", wantState: StateClosed},
+				{op: "allow", wantAllow: true, wantState: StateClosed},
+			},
+		},
+		{
+			name: "opens at threshold and rejects until timeout",
+			steps: []step{
+				{op: "failure", wantState: StateClosed},
+				{op: "failure", wantState: StateOpen},
+				{at: 59 * time.Second, op: "allow", wantAllow: false, wantState: StateOpen},
+			},
+		},
+		{
+			name: "half-open probe closes it",
+			steps: []step{
+				{op: "failure"},
+				{op: "failure", wantState: StateOpen},
+				{at: time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+				{at: time.Minute, op: "allow", wantAllow: false, wantState: StateHalfOpen},
+				{at: time.Minute, op: "success", wantState: StateClosed},
+				{at: time.Minute, op: "allow", wantAllow: true, wantState: StateClosed},
+			},
+		},
+		{
+			name: "failed probe opens it again",
+			steps: []step{
+				{op: "failure"},
+				{op: "failure", wantState: StateOpen},
+				{at: time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+				{at: time.Minute, op: "failure", wantState: StateOpen},
+				{at: time.Minute + 59*time.Second, op: "allow", wantAllow: false, wantState: StateOpen},
+				{at: 2 * time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+			},
+		},
+		{
+			name: "released probe lets the next one through",
+			steps: []step{
+				{op: "failure"},
+				{op: "failure", wantState: StateOpen},
+				{at: time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+				{at: time.Minute, op: "release", wantState: StateHalfOpen},
+				{at: time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+			},
+		},
+		{
+			name: "retry-after opens it below threshold",
+			steps: []step{
+				{op: "failure", retryAfter: 10 * time.Second, wantState: StateOpen},
+				{at: 9 * time.Second, op: "allow", wantAllow: false, wantState: StateOpen},
+				{at: 10 * time.Second, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+			},
+		},
+		{
+			name: "retry-after longer than the open timeout",
+			steps: []step{
+				{op: "failure"},
+				{op: "failure", retryAfter: 5 * time.Minute, wantState: StateOpen},
+				{at: 4 * time.Minute, op: "allow", wantAllow: false, wantState: StateOpen},
+				{at: 5 * time.Minute, op: "allow", wantAllow: true, wantState: StateHalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+			b := &breaker{host: "example.com", state: StateClosed}
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				switch s.op {
+				case "allow":
+					if got := b.allow(now); got != s.wantAllow {
+						t.Fatalf("step %d: allow returned %t, want %t", i, got, s.wantAllow)
+					}
+				case "release":
+					b.release()
+				case "success":
+					b.success()
+				case "failure":
+					b.failure(now, "status 503", s.retryAfter, opts)
+				default:
+					t.Fatalf("step %d: unknown op %q", i, s.op)
+				}
+				if s.wantState != "" && b.state != s.wantState {
+					t.Fatalf("step %d: state is %s, want %s", i, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerStatus(t *testing.T) {
+	now := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	opts := Options{FailureThreshold: 1, OpenTimeout: time.Minute}.withDefaults()
+	b := &breaker{host: "example.com", state: StateClosed}
+	b.failure(now, "status 502", 0, opts)
+
+	s := b.status(now)
+	if s.State != StateOpen || s.ConsecutiveFailures != 1 || s.LastError != "status 502" ||
+		s.OpenUntil == nil || !s.OpenUntil.Equal(now.Add(time.Minute)) {
+		t.Errorf("got %+v while open", s)
+	}
+	// the next request probes the host, so it is reported half-open
+	if s := b.status(now.Add(time.Minute)); s.State != StateHalfOpen {
+		t.Errorf("got state %s after the timeout, want %s", s.State, StateHalfOpen)
+	}
+}
+
+func TestBreakersEviction(t *testing.T) {
+	start := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	opts := Options{IdleTimeout: time.Hour, MaxHosts: 3, FailureThreshold: 1, OpenTimeout: 2 * time.Hour}.withDefaults()
+
+	tests := []struct {
+		name string
+		// hosts are requested a minute apart, a failing host opens its breaker
+		hosts   []string
+		failing map[string]bool
+		// then new is requested after wait
+		wait      time.Duration
+		new       string
+		wantHosts []string
+	}{
+		{
+			name:      "below the limit",
+			hosts:     []string{"a", "b"},
+			wait:      time.Minute,
+			new:       "c",
+			wantHosts: []string{"a", "b", "c"},
+		},
+		{
+			name:      "idle hosts expire",
+			hosts:     []string{"a", "b"},
+			wait:      time.Hour - 30*time.Second,
+			new:       "c",
+			wantHosts: []string{"b", "c"},
+		},
+		{
+			name:      "open breakers don't expire",
+			hosts:     []string{"a", "b"},
+			failing:   map[string]bool{"a": true},
+			wait:      time.Hour + time.Minute,
+			new:       "c",
+			wantHosts: []string{"a", "c"},
+		},
+		{
+			name:      "least recently used is dropped at the limit",
+			hosts:     []string{"a", "b", "c", "a"},
+			wait:      time.Minute,
+			new:       "d",
+			wantHosts: []string{"a", "c", "d"},
+		},
+		{
+			name:      "limit applies to open breakers too",
+			hosts:     []string{"a", "b", "c"},
+			failing:   map[string]bool{"a": true, "b": true, "c": true},
+			wait:      time.Minute,
+			new:       "d",
+			wantHosts: []string{"b", "c", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreakers(opts)
+			now := start
+			for _, h := range tt.hosts {
+				now = now.Add(time.Minute)
+				br := b.get(h, now)
+				if tt.failing[h] {
+					br.failure(now, "status 503", 0, opts)
+				}
+			}
+			b.get(tt.new, now.Add(tt.wait))
+
+			got := []string{}
+			for _, s := range b.status(now.Add(tt.wait)) {
+				got = append(got, s.Host)
+			}
+			if len(got) != len(tt.wantHosts) {
+				t.Fatalf("got hosts %v, want %v", got, tt.wantHosts)
+			}
+			for i := range got {
+				if got[i] != tt.wantHosts[i] {
+					t.Fatalf("got hosts %v, want %v", got, tt.wantHosts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package resilient provides an http.RoundTripper for scrapers that retries
+// failed requests and stops sending requests to hosts that are down.
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of
+// the host is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Options configure the Transport, zero values use the defaults.
+type Options struct {
+	// MaxRetries is how often a failed idempotent request is retried
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, it doubles with every
+	// further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. Requests with a longer Retry-After are not
+	// retried, the breaker of the host stays open until then instead.
+	MaxDelay time.Duration
+	// FailureThreshold is the number of consecutive failed requests after
+	// which the breaker of a host opens
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before a single request
+	// is let through to probe the host
+	OpenTimeout time.Duration
+	// IdleTimeout drops the breaker of a host not requested for that long,
+	// unless it is open
+	IdleTimeout time.Duration
+	// MaxHosts is the max number of hosts whose breakers are kept
+	MaxHosts int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 3
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = 250 * time.Millisecond
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = 5 * time.Second
+	}
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = 5
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = time.Minute
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = time.Hour
+	}
+	if o.MaxHosts <= 0 {
+		o.MaxHosts = 1000
+	}
+	return o
+}
+
+// Transport retries idempotent requests failing with a network error, 429 or
+// a 5xx status with exponential backoff, honoring Retry-After. Each host has
+// a circuit breaker that opens after too many consecutive failures.
+type Transport struct {
+	base     http.RoundTripper
+	opts     Options
+	breakers *breakers
+}
+
+// NewTransport wraps base, http.DefaultTransport is used if base is nil.
+func NewTransport(base http.RoundTripper, opts Options) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	opts = opts.withDefaults()
+	return &Transport{base: base, opts: opts, breakers: newBreakers(opts)}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	b := t.breakers.get(host, time.Now())
+	if !b.allow(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// the caller gave up, that says nothing about the host
+			b.release()
+			return resp, err
+		}
+		failure, retryAfter := classify(resp, err)
+		if failure == "" {
+			b.success()
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// the host did not answer in time
+			b.failure(time.Now(), failure, 0, t.opts)
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if !isIdempotent(req) || attempt >= t.opts.MaxRetries || wait > t.opts.MaxDelay || !fitsDeadline(req, wait) {
+			b.failure(time.Now(), failure, retryAfter, t.opts)
+			return resp, err
+		}
+
+		slog.Warn("request failed, retrying", "host", host, "attempt", attempt+1, "wait", wait, "err", failure)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			b.release()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Status returns the state of the breakers of the hosts requested recently.
+func (t *Transport) Status() []BreakerStatus {
+	return t.breakers.status(time.Now())
+}
+
+// classify returns why the request failed, empty if it did not. retryAfter is
+// set if the server asked to wait.
+func classify(resp *http.Response, err error) (string, time.Duration) {
+	if err != nil {
+		return err.Error(), 0
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return "status " + strconv.Itoa(resp.StatusCode), parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	case resp.StatusCode >= 500:
+		return "status " + strconv.Itoa(resp.StatusCode), 0
+	}
+	return "", 0
+}
+
+// parseRetryAfter parses the header in seconds or as http date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := min(t.opts.BaseDelay<<attempt, t.opts.MaxDelay)
+	// equal jitter, so concurrent requests don't retry in lockstep
+	return d/2 + rand.N(d/2+1)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+func fitsDeadline(req *http.Request, wait time.Duration) bool {
+	deadline, ok := req.Context().Deadline()
+	return !ok || time.Until(deadline) > wait
+}
@@ -0,0 +1,195 @@
+package resilient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"soon", 0},
+		{"1.5", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		// only the http date format is accepted
+		{"Tue, 14 May 2024 12:01:00 +0000", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+// response is what the test server answers to a single request.
+type response struct {
+	status     int
+	retryAfter string
+}
+
+func TestTransport(t *testing.T) {
+	opts := Options{
+		MaxRetries:       2,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         50 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}
+	tests := []struct {
+		name      string
+		method    string
+		responses []response
+		// wantStatus is the status of the last response
+		wantStatus   int
+		wantRequests int
+		wantState    string
+	}{
+		{
+			name:         "success",
+			responses:    []response{{status: 200}},
+			wantStatus:   200,
+			wantRequests: 1,
+			wantState:    StateClosed,
+		},
+		{
+			name:         "client errors are not retried",
+			responses:    []response{{status: 404}},
+			wantStatus:   404,
+			wantRequests: 1,
+			wantState:    StateClosed,
+		},
+		{
+			name:         "server error retried",
+			responses:    []response{{status: 502}, {status: 503}, {status: 200}},
+			wantStatus:   200,
+			wantRequests: 3,
+			wantState:    StateClosed,
+		},
+		{
+			name:         "retries exhausted",
+			responses:    []response{{status: 500}, {status: 500}, {status: 500}, {status: 200}},
+			wantStatus:   500,
+			wantRequests: 3,
+			wantState:    StateClosed,
+		},
+		{
+			name:         "short retry-after is waited for",
+			responses:    []response{{status: 429, retryAfter: "0"}, {status: 200}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantState:    StateClosed,
+		},
+		{
+			name:         "long retry-after opens the breaker",
+			responses:    []response{{status: 429, retryAfter: "120"}, {status: 200}},
+			wantStatus:   429,
+			wantRequests: 1,
+			wantState:    StateOpen,
+		},
+		{
+			name:         "retry-after on 503",
+			responses:    []response{{status: 503, retryAfter: "3600"}, {status: 200}},
+			wantStatus:   503,
+			wantRequests: 1,
+			wantState:    StateOpen,
+		},
+		{
+			name:         "post is not retried",
+			method:       http.MethodPost,
+			responses:    []response{{status: 503}, {status: 200}},
+			wantStatus:   503,
+			wantRequests: 1,
+			wantState:    StateClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1)) - 1
+				resp := tt.responses[min(n, len(tt.responses)-1)]
+				if resp.retryAfter != "" {
+					w.Header().Set("Retry-After", resp.retryAfter)
+				}
+				w.WriteHeader(resp.status)
+			}))
+			defer srv.Close()
+
+			transport := NewTransport(srv.Client().Transport, opts)
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+			status := transport.Status()
+			if len(status) != 1 || status[0].State != tt.wantState {
+				t.Errorf("got breakers %+v, want one %s", status, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestTransportCircuitOpen(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	transport := NewTransport(srv.Client().Transport, Options{
+		MaxRetries:       1,
+		BaseDelay:        time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	})
+	httpc := &http.Client{Transport: transport}
+
+	// every request is retried once, so the second one trips the breaker
+	for i := range 2 {
+		resp, err := httpc.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	_, err := httpc.Get(srv.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v while the breaker is open, want ErrCircuitOpen", err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("server got %d requests, want 4", got)
+	}
+	status := transport.Status()
+	if len(status) != 1 || status[0].State != StateOpen || status[0].ConsecutiveFailures != 2 ||
+		!strings.Contains(status[0].LastError, "502") {
+		t.Errorf("got breakers %+v", status)
+	}
+}
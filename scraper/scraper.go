@@ -2,12 +2,40 @@ package scraper
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/floj/serializer-go/model"
 )
 
+// ErrSkipItem is returned by FetchItem if the scraper can't provide an update
+// for a single item. The stored story is left untouched.
+var ErrSkipItem = errors.New("scraper can't fetch single item")
+
 type Scraper interface {
 	Name() string
 	FetchItem(ctx context.Context, refId string) (model.Story, bool, error)
 	FetchItems(ctx context.Context) ([]model.Story, error)
 }
+
+// Schedule is how often and how long a scraper runs. Zero values fall back to
+// the defaults.
+type Schedule struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// Jitter is the max random delay added to the interval, so scrapers of
+	// the same source don't run in lockstep
+	Jitter time.Duration
+}
+
+// Scheduled is implemented by scrapers that need a different schedule than
+// the default one, e.g. because their source is updated less often.
+type Scheduled interface {
+	Schedule() Schedule
+}
+
+// Commented is implemented by scrapers that can fetch the discussion of a
+// story. Its comment tree is stored when the story leaves the front page.
+type Commented interface {
+	FetchComments(ctx context.Context, refId string) ([]model.Comment, error)
+}
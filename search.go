@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+)
+
+const searchDateLayout = "2006-01-02"
+
+type SearchResponse struct {
+	Stories []model.StoryJSON `json:"stories"`
+	// offset of the next page, only set if there might be more stories
+	NextOffset *int64 `json:"next_offset,omitempty"`
+}
+
+// searchParamsFromQuery reads the search from the query parameters. from and
+// to are dates in UTC, to includes the whole day.
+func searchParamsFromQuery(c echo.Context) (model.SearchParams, error) {
+	p := model.SearchParams{Query: strings.TrimSpace(c.QueryParam("q"))}
+	date := func(name string) (time.Time, error) {
+		v := c.QueryParam(name)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(searchDateLayout, v)
+		if err != nil {
+			return t, echo.NewHTTPError(http.StatusBadRequest, "invalid value for "+name+", expected YYYY-MM-DD")
+		}
+		return t, nil
+	}
+	var err error
+	if p.From, err = date("from"); err != nil {
+		return p, err
+	}
+	if p.To, err = date("to"); err != nil {
+		return p, err
+	}
+	if !p.To.IsZero() {
+		p.To = p.To.AddDate(0, 0, 1)
+	}
+
+	filter := filterFromQuery(c)
+	p.Scrapers, p.Types = filter.Scrapers, filter.Types
+
+	minScore, err := queryInt(c, "min_score", 0)
+	if err != nil {
+		return p, err
+	}
+	limit, err := queryInt(c, "limit", apiDefaultLimit)
+	if err != nil {
+		return p, err
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		return p, err
+	}
+	p.MinScore = int32(min(minScore, math.MaxInt32))
+	p.Limit = int32(min(max(limit, 1), apiMaxLimit))
+	p.Offset = int32(min(offset, math.MaxInt32))
+	return p, nil
+}
+
+// searchTypes returns the scraper:type keys of the story types of the given
+// sources.
+func searchTypes(sources []string) []string {
+	types := []string{}
+	for _, scraper := range sources {
+		for _, typ := range model.ScraperTypes(scraper) {
+			types = append(types, model.TypeFilterKey(scraper, typ))
+		}
+	}
+	slices.Sort(types)
+	return types
+}
+
+// registerSearch adds the search page and its API endpoint.
+func registerSearch(app *echo.Echo, db *model.DB, conf *config.Store, scheduler *job.Scheduler) {
+	app.GET("/search", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		s, _, err := sessionFromCookie(c, db, conf)
+		if err != nil {
+			return err
+		}
+		p, err := searchParamsFromQuery(c)
+		if err != nil {
+			return err
+		}
+		form := views.SearchForm{
+			Query:    p.Query,
+			From:     c.QueryParam("from"),
+			To:       c.QueryParam("to"),
+			Scraper:  strings.Join(p.Scrapers, ","),
+			Type:     strings.Join(p.Types, ","),
+			Scrapers: scheduler.Sources(),
+			Types:    searchTypes(scheduler.Sources()),
+		}
+		if p.MinScore > 0 {
+			form.MinScore = strconv.Itoa(int(p.MinScore))
+		}
+
+		// an empty form only shows the form
+		results := []model.StoryGroup{}
+		if len(c.QueryParams()) > 0 {
+			form.Searched = true
+			stories, err := db.SearchStories(ctx, p)
+			if err != nil {
+				return err
+			}
+			muter, err := model.LoadMuter(ctx, db.Queries())
+			if err != nil {
+				return err
+			}
+			for _, g := range model.GroupSearchResults(stories) {
+				if muter.Muted(&g.Story) {
+					if !s.ShowMuted {
+						continue
+					}
+					g.Muted = true
+				}
+				results = append(results, g)
+			}
+			if err := db.LoadReadingTimes(ctx, results); err != nil {
+				return err
+			}
+			if len(stories) == int(p.Limit) {
+				next := url.Values{}
+				for k, v := range c.QueryParams() {
+					next[k] = v
+				}
+				next.Set("offset", strconv.Itoa(int(p.Offset+p.Limit)))
+				form.NextURL = "/search?" + next.Encode()
+			}
+		}
+		return views.Search(form, results, s.LastRead, s.RewriteLinks).Render(ctx, c.Response())
+	})
+
+	// Stories are ranked by relevance with Postgres, newest first with SQLite.
+	app.GET("/api/v1/search", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		p, err := searchParamsFromQuery(c)
+		if err != nil {
+			return err
+		}
+		stories, err := db.SearchStories(ctx, p)
+		if err != nil {
+			return err
+		}
+		muter, err := model.LoadMuter(ctx, db.Queries())
+		if err != nil {
+			return err
+		}
+
+		// muted stories and duplicates of other results are left out, the
+		// offset still counts them
+		resp := SearchResponse{Stories: make([]model.StoryJSON, 0, len(stories))}
+		for _, g := range model.GroupSearchResults(stories) {
+			if muter.Muted(&g.Story) {
+				continue
+			}
+			resp.Stories = append(resp.Stories, g.Story.JSON())
+		}
+		if len(stories) == int(p.Limit) {
+			next := int64(p.Offset + p.Limit)
+			resp.NextOffset = &next
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+}
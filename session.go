@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/config"
+	"github.com/floj/serializer-go/job"
+	"github.com/floj/serializer-go/model"
+	"github.com/floj/serializer-go/views"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionTokenBytes is the amount of random bytes in a session token, 16 bytes
+// make the token impossible to guess
+const sessionTokenBytes = 16
+
+func newSessionToken() (string, error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate session token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// defaultSession is the session of readers without one, with the filters
+// configured as defaults.
+func defaultSession(ui config.UIConfig) model.Session {
+	return model.Session{
+		HiddenScrapers: strings.Join(ui.HiddenScrapers, ","),
+		HiddenTypes:    strings.Join(ui.HiddenTypes, ","),
+		ShowMuted:      ui.ShowMuted,
+		RewriteLinks:   ui.RewriteLinks,
+	}
+}
+
+// createSession stores a new session with the filters of s.
+func createSession(ctx context.Context, db *model.DB, s model.Session, last int64) (model.Session, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return model.Session{}, err
+	}
+	s, err = db.Queries().CreateSession(ctx, model.CreateSessionParams{
+		Token:          token,
+		LastRead:       last,
+		HiddenScrapers: s.HiddenScrapers,
+		HiddenTypes:    s.HiddenTypes,
+		ShowMuted:      s.ShowMuted,
+		RewriteLinks:   s.RewriteLinks,
+	})
+	if err != nil {
+		return model.Session{}, fmt.Errorf("could not create session: %w", err)
+	}
+	return s, nil
+}
+
+// getSession returns the session with the given token, found is false if the
+// session does not exist
+func getSession(ctx context.Context, db *model.DB, token string) (model.Session, bool, error) {
+	if token == "" {
+		return model.Session{}, false, nil
+	}
+	s, err := db.Queries().GetSession(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Session{}, false, nil
+	}
+	if err != nil {
+		return model.Session{}, false, fmt.Errorf("could not get session: %w", err)
+	}
+	return s, true, nil
+}
+
+// sessionFromCookie returns the session referenced by the cookie. Cookies
+// from before sessions existed only carry the last read id, those readers are
+// moved onto a new session transparently. found is false for new readers.
+func sessionFromCookie(c echo.Context, db *model.DB, conf *config.Store) (model.Session, bool, error) {
+	ctx := c.Request().Context()
+	cv := getCookieVal(c.Cookie(cookieName))
+
+	s, found, err := getSession(ctx, db, cv.Session)
+	if err != nil || found {
+		return s, found, err
+	}
+	if cv.Last <= 0 {
+		return defaultSession(conf.Get().UI), false, nil
+	}
+
+	s, err = createSession(ctx, db, defaultSession(conf.Get().UI), cv.Last)
+	if err != nil {
+		return model.Session{}, false, err
+	}
+	slog.Info("moved cookie onto session", "last", cv.Last)
+	return s, true, writeCookie(c, s, conf.Get().CookieSecure)
+}
+
+func saveSession(ctx context.Context, db *model.DB, s model.Session, found bool, last int64) (model.Session, error) {
+	if !found {
+		return createSession(ctx, db, s, last)
+	}
+	s, err := db.Queries().UpdateSessionLastRead(ctx, model.UpdateSessionLastReadParams{
+		LastRead:  last,
+		UpdatedAt: time.Now(),
+		Token:     s.Token,
+	})
+	if err != nil {
+		return model.Session{}, fmt.Errorf("could not update session: %w", err)
+	}
+	return s, nil
+}
+
+// saveFilters stores the scrapers and types the reader unchecked in the
+// settings panel, every scraper and type that is not sent is hidden.
+func saveFilters(c echo.Context, db *model.DB, s model.Session, found bool, sources []string) (model.Session, error) {
+	ctx := c.Request().Context()
+	params, err := c.FormParams()
+	if err != nil {
+		return model.Session{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	hiddenScrapers := []string{}
+	hiddenTypes := []string{}
+	for _, scraper := range sources {
+		if !slices.Contains(params["scraper"], scraper) {
+			hiddenScrapers = append(hiddenScrapers, scraper)
+		}
+		types := model.ScraperTypes(scraper)
+		if len(types) < 2 {
+			// the only type is toggled with the scraper
+			continue
+		}
+		for _, typ := range types {
+			key := model.TypeFilterKey(scraper, typ)
+			if !slices.Contains(params["type"], key) {
+				hiddenTypes = append(hiddenTypes, key)
+			}
+		}
+	}
+
+	if !found {
+		s, err = createSession(ctx, db, s, 0)
+		if err != nil {
+			return model.Session{}, err
+		}
+	}
+	s, err = db.Queries().UpdateSessionFilters(ctx, model.UpdateSessionFiltersParams{
+		HiddenScrapers: strings.Join(hiddenScrapers, ","),
+		HiddenTypes:    strings.Join(hiddenTypes, ","),
+		ShowMuted:      c.FormValue("show_muted") == "on",
+		RewriteLinks:   c.FormValue("rewrite_links") == "on",
+		UpdatedAt:      time.Now(),
+		Token:          s.Token,
+	})
+	if err != nil {
+		return model.Session{}, fmt.Errorf("could not update session filters: %w", err)
+	}
+	slog.Info("updated filters", "scrapers", s.HiddenScrapers, "types", s.HiddenTypes, "showMuted", s.ShowMuted, "rewriteLinks", s.RewriteLinks)
+	return s, nil
+}
+
+func settingsFor(s model.Session, sources []string) views.Settings {
+	settings := views.Settings{Session: s.Token, ShowMuted: s.ShowMuted, RewriteLinks: s.RewriteLinks}
+	for _, scraper := range sources {
+		src := views.SourceToggle{Scraper: scraper, Shown: s.ShowsScraper(scraper)}
+		for _, typ := range model.ScraperTypes(scraper) {
+			src.Types = append(src.Types, views.TypeToggle{
+				Key:   model.TypeFilterKey(scraper, typ),
+				Type:  typ,
+				Shown: s.ShowsType(scraper, typ),
+			})
+		}
+		settings.Sources = append(settings.Sources, src)
+	}
+	return settings
+}
+
+func renderIndex(c echo.Context, db *model.DB, s model.Session, sources []string) error {
+	stories, err := getStories(c.Request().Context(), db, s)
+	if err != nil {
+		return err
+	}
+	unread := unreadCount(stories, s.LastRead)
+	return views.Index(stories, s.LastRead, unread, settingsFor(s, sources)).Render(c.Request().Context(), c.Response())
+}
+
+// registerSessions adds the /s/{token} routes. Opening the link adopts the
+// session on this device, so the same reading position is shared between all
+// devices that opened it.
+func registerSessions(app *echo.Echo, db *model.DB, conf *config.Store, scheduler *job.Scheduler) {
+	app.GET("/s/:token", func(c echo.Context) error {
+		s, found, err := getSession(c.Request().Context(), db, c.Param("token"))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		if err := writeCookie(c, s, conf.Get().CookieSecure); err != nil {
+			return err
+		}
+		return renderIndex(c, db, s, scheduler.Sources())
+	})
+
+	app.POST("/s/:token", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		s, found, err := getSession(ctx, db, c.Param("token"))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		last := getLastIdFromPOST(c)
+		slog.Info("updating last", "last", last)
+		s, err = saveSession(ctx, db, s, true, last)
+		if err != nil {
+			return err
+		}
+		if err := writeCookie(c, s, conf.Get().CookieSecure); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, views.SessionPath(s.Token))
+	})
+
+	app.POST("/settings", func(c echo.Context) error {
+		s, found, err := sessionFromCookie(c, db, conf)
+		if err != nil {
+			return err
+		}
+		s, err = saveFilters(c, db, s, found, scheduler.Sources())
+		if err != nil {
+			return err
+		}
+		if err := writeCookie(c, s, conf.Get().CookieSecure); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	app.POST("/s/:token/settings", func(c echo.Context) error {
+		s, found, err := getSession(c.Request().Context(), db, c.Param("token"))
+		if err != nil {
+			return err
+		}
+		if !found {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		s, err = saveFilters(c, db, s, true, scheduler.Sources())
+		if err != nil {
+			return err
+		}
+		if err := writeCookie(c, s, conf.Get().CookieSecure); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, views.SessionPath(s.Token))
+	})
+}
@@ -0,0 +1,101 @@
+package views
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/floj/serializer-go/model"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func commentsPath(id int64) string {
+	return fmt.Sprintf("/stories/%d/comments", id)
+}
+
+func commentAge(c model.Comment) string {
+	if c.CreatedAt.IsZero() {
+		return ""
+	}
+	return humanize.Time(c.CreatedAt)
+}
+
+func replies(c model.Comment) string {
+	n := model.CountComments(c.Children)
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return "1 reply"
+	}
+	return fmt.Sprintf("%d replies", n)
+}
+
+// elements kept in comments, HN only uses these
+var commentElements = map[atom.Atom]bool{
+	atom.P: true, atom.A: true, atom.I: true, atom.Em: true, atom.B: true,
+	atom.Strong: true, atom.Pre: true, atom.Code: true, atom.Br: true,
+}
+
+// commentHTML returns the HTML of the comment with everything but simple
+// formatting and http links removed, the snapshot is not trusted.
+func commentHTML(text string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil {
+		return html.EscapeString(text)
+	}
+	var b strings.Builder
+	var render func(*html.Node)
+	render = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(html.EscapeString(n.Data))
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+			return
+		}
+		keep := commentElements[n.DataAtom]
+		if keep {
+			b.WriteString("<" + n.Data)
+			if n.DataAtom == atom.A {
+				if href := safeHref(n); href != "" {
+					b.WriteString(` href="` + html.EscapeString(href) + `" rel="nofollow noreferrer"`)
+				}
+			}
+			b.WriteString(">")
+		}
+		if n.DataAtom == atom.Br {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(c)
+		}
+		if keep {
+			b.WriteString("</" + n.Data + ">")
+		}
+	}
+	for _, n := range nodes {
+		render(n)
+	}
+	return b.String()
+}
+
+func safeHref(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key != "href" {
+			continue
+		}
+		u, err := url.Parse(a.Val)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+package views
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+// FeedInfo describes the feed as a whole, URLs have to be absolute.
+type FeedInfo struct {
+	Title   string
+	SiteURL string
+	SelfURL string
+	Updated time.Time
+}
+
+// entryID is stable for the life time of a story, independent of the host
+// the feed is served from.
+func entryID(s model.Story) string {
+	return fmt.Sprintf("urn:serializer-go:story:%d", s.ID)
+}
+
+func entryLink(s model.Story) string {
+	if u := s.RewrittenURL(); u != "" {
+		return u
+	}
+	if u := s.LinkURL(); u != "#" {
+		return u
+	}
+	return s.CommentsURL()
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// AtomFeed renders stories as Atom 1.0 feed.
+func AtomFeed(info FeedInfo, stories []model.Story) ([]byte, error) {
+	f := atomFeed{
+		ID:      info.SiteURL,
+		Title:   info.Title,
+		Updated: info.Updated.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "serializer-go"},
+		Links: []atomLink{
+			{Href: info.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: info.SiteURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, s := range stories {
+		e := atomEntry{
+			ID:        entryID(s),
+			Title:     s.Title,
+			Updated:   s.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: s.CreatedAt.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: entryLink(s), Rel: "alternate"}},
+			Categories: []atomCategory{
+				{Term: s.Scraper, Scheme: "urn:serializer-go:scraper"},
+				{Term: s.Type, Scheme: "urn:serializer-go:type"},
+			},
+		}
+		if s.By != "" {
+			e.Author = &atomPerson{Name: s.By}
+		}
+		if u := s.CommentsURL(); u != "#" {
+			e.Links = append(e.Links, atomLink{Href: u, Rel: "replies", Type: "text/html"})
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return marshalFeed(f)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssCategory struct {
+	Value  string `xml:",chardata"`
+	Domain string `xml:"domain,attr,omitempty"`
+}
+
+type rssItem struct {
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link"`
+	GUID       rssGUID       `xml:"guid"`
+	PubDate    string        `xml:"pubDate"`
+	Comments   string        `xml:"comments,omitempty"`
+	Categories []rssCategory `xml:"category"`
+}
+
+// RSSFeed renders stories as RSS 2.0 feed.
+func RSSFeed(info FeedInfo, stories []model.Story) ([]byte, error) {
+	f := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         info.Title,
+			Link:          info.SiteURL,
+			Description:   "Stories from Hacker News and others in the order they were collected",
+			LastBuildDate: info.Updated.UTC().Format(time.RFC1123Z),
+			AtomLink:      atomLink{Href: info.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	for _, s := range stories {
+		itm := rssItem{
+			Title:   s.Title,
+			Link:    entryLink(s),
+			GUID:    rssGUID{Value: entryID(s)},
+			PubDate: s.CreatedAt.UTC().Format(time.RFC1123Z),
+			Categories: []rssCategory{
+				{Value: s.Scraper, Domain: "urn:serializer-go:scraper"},
+				{Value: s.Type, Domain: "urn:serializer-go:type"},
+			},
+		}
+		if u := s.CommentsURL(); u != "#" {
+			itm.Comments = u
+		}
+		f.Channel.Items = append(f.Channel.Items, itm)
+	}
+	return marshalFeed(f)
+}
+
+func marshalFeed(v any) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
@@ -0,0 +1,39 @@
+package views
+
+import (
+	"net/url"
+
+	"github.com/floj/serializer-go/model"
+)
+
+// titleURL is the link of the title, rewritten unless the reader turned that
+// off.
+func titleURL(s *model.Story, rewrite bool) string {
+	if r := s.RewrittenURL(); rewrite && r != "" {
+		return r
+	}
+	return s.LinkURL()
+}
+
+// altURL is the link not used by the title, empty if no transformer applies.
+func altURL(s *model.Story, rewrite bool) string {
+	r := s.RewrittenURL()
+	if r == "" {
+		return ""
+	}
+	if rewrite {
+		return s.LinkURL()
+	}
+	return r
+}
+
+func altLabel(s *model.Story, rewrite bool) string {
+	if rewrite {
+		return "original"
+	}
+	u, err := url.Parse(s.RewrittenURL())
+	if err != nil || u.Host == "" {
+		return "rewritten"
+	}
+	return "via " + u.Host
+}
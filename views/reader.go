@@ -0,0 +1,26 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/floj/serializer-go/model"
+)
+
+func readerPath(id int64) string {
+	return fmt.Sprintf("/read/%d", id)
+}
+
+func readingTime(minutes int32) string {
+	return fmt.Sprintf("%d min read", minutes)
+}
+
+// readerUnavailable explains why there is no article text to show.
+func readerUnavailable(a model.Article) string {
+	switch a.Status {
+	case model.ArticleFailed:
+		return "The article text could not be extracted: " + a.Error
+	case model.ArticleRetry:
+		return "The article could not be fetched so far: " + a.Error
+	}
+	return "The article has not been fetched yet."
+}
@@ -0,0 +1,34 @@
+package views
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/floj/serializer-go/model"
+)
+
+func runsFilterURL(scraper string, failedOnly bool) string {
+	q := url.Values{}
+	if scraper != "" {
+		q.Set("scraper", scraper)
+	}
+	if failedOnly {
+		q.Set("failed", "true")
+	}
+	if len(q) == 0 {
+		return "/admin/runs"
+	}
+	return "/admin/runs?" + q.Encode()
+}
+
+func runDuration(r model.ScrapeRun) string {
+	return r.FinishedAt.Sub(r.StartedAt).Round(10 * time.Millisecond).String()
+}
+
+func runErrors(r model.ScrapeRun) []string {
+	if r.Errors == "" {
+		return nil
+	}
+	return strings.Split(r.Errors, "\n")
+}
@@ -0,0 +1,27 @@
+package views
+
+import "strings"
+
+// SearchForm is the search as entered by the reader, together with the
+// choices for the filters.
+type SearchForm struct {
+	Query    string
+	From     string
+	To       string
+	Scraper  string
+	Type     string
+	MinScore string
+	Scrapers []string
+	Types    []string
+	// Searched is set when the form was submitted, otherwise there are no results to show
+	Searched bool
+	// NextURL links to the next page of results, empty on the last page
+	NextURL string
+}
+
+func searchTitle(form SearchForm) string {
+	if form.Query == "" {
+		return "Search"
+	}
+	return strings.TrimSpace(form.Query) + " - Search"
+}
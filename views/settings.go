@@ -0,0 +1,29 @@
+package views
+
+// Settings is what the settings panel shows to the reader.
+type Settings struct {
+	Session      string
+	Sources      []SourceToggle
+	ShowMuted    bool
+	RewriteLinks bool
+}
+
+// SourceToggle is a scraper the reader can hide, together with its types.
+type SourceToggle struct {
+	Scraper string
+	Shown   bool
+	Types   []TypeToggle
+}
+
+type TypeToggle struct {
+	Key   string
+	Type  string
+	Shown bool
+}
+
+func settingsPath(session string) string {
+	if session == "" {
+		return "/settings"
+	}
+	return SessionPath(session) + "/settings"
+}
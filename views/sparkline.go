@@ -0,0 +1,51 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/floj/serializer-go/model"
+)
+
+const (
+	sparklineWidth  = 300
+	sparklineHeight = 40
+)
+
+// sparkline scales the points into the sparkline box and returns them in the
+// format of the points attribute of an svg polyline.
+func sparkline(points []model.HistoryPoint) string {
+	if len(points) == 0 {
+		return ""
+	}
+	start, end := points[0].At, points[len(points)-1].At
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points {
+		lo = min(lo, p.Value)
+		hi = max(hi, p.Value)
+	}
+
+	duration := end.Sub(start).Seconds()
+	span := float64(hi - lo)
+
+	coords := make([]string, 0, len(points))
+	for _, p := range points {
+		x := 0.0
+		if duration > 0 {
+			x = p.At.Sub(start).Seconds() / duration * sparklineWidth
+		}
+		y := float64(sparklineHeight) / 2
+		if span > 0 {
+			y = sparklineHeight - float64(p.Value-lo)/span*sparklineHeight
+		}
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(coords, " ")
+}
+
+func seriesRange(points []model.HistoryPoint) string {
+	if len(points) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d → %d", points[0].Value, points[len(points)-1].Value)
+}